@@ -0,0 +1,428 @@
+// Package anthropictest provides an in-memory Anthropic API for testing code
+// that uses goanthropic. A Server returns canned responses in order and
+// records every request it receives:
+//
+//	srv := anthropictest.NewServer(anthropictest.TextResponse("Hello"))
+//	defer srv.Close()
+//	client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+//	resp, err := client.ChatMe(ctx, "Hi", nil)
+//	srv.AssertModel(t, "claude-3-5-sonnet-20241022")
+//
+// Besides messages requests, streamed or not, the server answers token
+// counting and model listing. Message Batches are not supported: batch
+// requests receive a 404 not_found_error.
+package anthropictest
+
+import (
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// Server is a fake Anthropic API backed by httptest
+type Server struct {
+    *httptest.Server
+
+    mu            sync.Mutex
+    replies       []reply
+    requests      []types.Request
+    countRequests []types.CountTokensRequest
+    tokenCounter  func(types.CountTokensRequest) int
+    models        []types.Model
+}
+
+// reply is one queued answer to a message request: either a response or a
+// body sent as is with status
+type reply struct {
+    response *types.AnthropicResponse
+    status   int
+    body     []byte
+}
+
+// NewServer starts a server that answers successive message requests with
+// responses, in order. Once they run out it answers with an API error.
+func NewServer(responses ...types.AnthropicResponse) *Server {
+    s := &Server{tokenCounter: estimateTokens}
+    s.Enqueue(responses...)
+    s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+    return s
+}
+
+// Client returns a client that sends its requests to the server
+func (s *Server) Client(opts ...goanthropic.ClientOption) *goanthropic.AnthropicClient {
+    opts = append([]goanthropic.ClientOption{goanthropic.WithBaseURL(s.URL)}, opts...)
+    return goanthropic.NewClient("test-key", opts...)
+}
+
+// Enqueue adds responses to be returned after the pending ones
+func (s *Server) Enqueue(responses ...types.AnthropicResponse) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for i := range responses {
+        s.replies = append(s.replies, reply{response: &responses[i]})
+    }
+}
+
+// EnqueueError adds an API error with the given status, such as 413 or 429,
+// to be returned after the pending replies
+func (s *Server) EnqueueError(status int, errorType, message string) {
+    body, _ := json.Marshal(errorBody(errorType, message))
+    s.EnqueueRaw(status, string(body))
+}
+
+// EnqueueRaw adds a reply with the given status and body, sent exactly as
+// given, for example to simulate a truncated response
+func (s *Server) EnqueueRaw(status int, body string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.replies = append(s.replies, reply{status: status, body: []byte(body)})
+}
+
+// SetModels sets the models listed by the models endpoint
+func (s *Server) SetModels(models ...types.Model) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.models = append([]types.Model(nil), models...)
+}
+
+// SetTokenCounter sets how the token counting endpoint counts a request. By
+// default it estimates one token per four characters of text.
+func (s *Server) SetTokenCounter(counter func(types.CountTokensRequest) int) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.tokenCounter = counter
+}
+
+// CountRequests returns the token counting requests received so far
+func (s *Server) CountRequests() []types.CountTokensRequest {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]types.CountTokensRequest(nil), s.countRequests...)
+}
+
+// Requests returns the message requests received so far
+func (s *Server) Requests() []types.Request {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    return append([]types.Request(nil), s.requests...)
+}
+
+// LastRequest returns the most recent message request
+func (s *Server) LastRequest() (types.Request, bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    if len(s.requests) == 0 {
+        return types.Request{}, false
+    }
+    return s.requests[len(s.requests)-1], true
+}
+
+// handle routes a single request to its endpoint
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+    switch {
+    case r.Method == http.MethodPost && r.URL.Path == "/v1/messages":
+        s.handleMessages(w, r)
+    case r.Method == http.MethodPost && r.URL.Path == "/v1/messages/count_tokens":
+        s.handleCountTokens(w, r)
+    case r.Method == http.MethodGet && r.URL.Path == "/v1/models":
+        s.handleModels(w)
+    default:
+        writeError(w, http.StatusNotFound, "not_found_error", fmt.Sprintf("%s %s is not supported", r.Method, r.URL.Path))
+    }
+}
+
+// handleMessages answers a message request with the next queued reply
+func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+        return
+    }
+    req, err := decodeRequest(body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+        return
+    }
+
+    s.mu.Lock()
+    s.requests = append(s.requests, req)
+    if len(s.replies) == 0 {
+        s.mu.Unlock()
+        writeError(w, http.StatusInternalServerError, "api_error", "no canned response left")
+        return
+    }
+    next := s.replies[0]
+    s.replies = s.replies[1:]
+    s.mu.Unlock()
+
+    if next.response == nil {
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(next.status)
+        w.Write(next.body)
+        return
+    }
+    resp := *next.response
+    if resp.Model == "" {
+        resp.Model = req.Model
+    }
+    if req.Stream {
+        writeStream(w, resp)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// handleCountTokens answers a token counting request with the token counter
+func (s *Server) handleCountTokens(w http.ResponseWriter, r *http.Request) {
+    body, err := io.ReadAll(r.Body)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+        return
+    }
+    var raw struct {
+        types.CountTokensRequest
+        System json.RawMessage `json:"system"`
+    }
+    if err := json.Unmarshal(body, &raw); err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+        return
+    }
+    req := raw.CountTokensRequest
+    blocks, err := decodeSystem(raw.System)
+    if err != nil {
+        writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+        return
+    }
+    req.System = types.SystemText(blocks)
+    if strings.HasPrefix(string(raw.System), "[") {
+        req.SystemBlocks = blocks
+    }
+
+    s.mu.Lock()
+    s.countRequests = append(s.countRequests, req)
+    counter := s.tokenCounter
+    s.mu.Unlock()
+
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(types.CountTokensResponse{InputTokens: counter(req)})
+}
+
+// handleModels lists the models set with SetModels on a single page
+func (s *Server) handleModels(w http.ResponseWriter) {
+    s.mu.Lock()
+    models := append([]types.Model{}, s.models...)
+    s.mu.Unlock()
+
+    page := map[string]interface{}{"data": models, "has_more": false}
+    if len(models) > 0 {
+        page["first_id"] = models[0].ID
+        page["last_id"] = models[len(models)-1].ID
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(page)
+}
+
+// estimateTokens is the default token counter: one token per four characters
+// of system and message text
+func estimateTokens(req types.CountTokensRequest) int {
+    chars := len(req.System)
+    for _, msg := range req.Messages {
+        for _, block := range msg.Content {
+            chars += len(block.Text) + len(block.Content)
+        }
+    }
+    return chars/4 + 1
+}
+
+// decodeRequest parses a request body, accepting the system prompt either as
+// a string or as the block form sent when it is cached
+func decodeRequest(body []byte) (types.Request, error) {
+    var raw struct {
+        types.Request
+        System json.RawMessage `json:"system"`
+    }
+    if err := json.Unmarshal(body, &raw); err != nil {
+        return types.Request{}, fmt.Errorf("error parsing request: %w", err)
+    }
+    req := raw.Request
+    blocks, err := decodeSystem(raw.System)
+    if err != nil {
+        return types.Request{}, err
+    }
+    req.System = types.SystemText(blocks)
+    if strings.HasPrefix(string(raw.System), "[") {
+        req.SystemBlocks = blocks
+    }
+    return req, nil
+}
+
+// decodeSystem parses a system prompt sent either as a string or as blocks.
+// A string is returned as a single block.
+func decodeSystem(raw json.RawMessage) ([]types.SystemBlock, error) {
+    if len(raw) == 0 {
+        return nil, nil
+    }
+    var text string
+    if err := json.Unmarshal(raw, &text); err == nil {
+        return []types.SystemBlock{{Text: text}}, nil
+    }
+    var blocks []types.MessageContent
+    if err := json.Unmarshal(raw, &blocks); err != nil {
+        return nil, fmt.Errorf("error parsing system prompt: %w", err)
+    }
+    system := make([]types.SystemBlock, len(blocks))
+    for i, block := range blocks {
+        system[i] = types.SystemBlock{Text: block.Text, CacheControl: block.CacheControl}
+    }
+    return system, nil
+}
+
+// writeStream sends resp as the server-sent events of a streamed response
+func writeStream(w http.ResponseWriter, resp types.AnthropicResponse) {
+    w.Header().Set("Content-Type", "text/event-stream")
+    send := func(event string, data map[string]interface{}) {
+        data["type"] = event
+        encoded, _ := json.Marshal(data)
+        fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded)
+    }
+
+    start := resp
+    start.Content = []types.MessageContent{}
+    start.StopReason = ""
+    start.Usage.OutputTokens = 0
+    send("message_start", map[string]interface{}{"message": start})
+    for i, block := range resp.Content {
+        opening := block
+        var deltas []map[string]interface{}
+        switch block.Type {
+        case types.ContentTypeText:
+            opening.Text = ""
+            deltas = append(deltas, map[string]interface{}{"type": "text_delta", "text": block.Text})
+        case types.ContentTypeToolUse:
+            opening.Input = json.RawMessage("{}")
+            deltas = append(deltas, map[string]interface{}{"type": "input_json_delta", "partial_json": string(block.Input)})
+        case types.ContentTypeThinking:
+            opening.Thinking, opening.Signature = "", ""
+            deltas = append(deltas,
+                map[string]interface{}{"type": "thinking_delta", "thinking": block.Thinking},
+                map[string]interface{}{"type": "signature_delta", "signature": block.Signature})
+        }
+        send("content_block_start", map[string]interface{}{"index": i, "content_block": opening})
+        for _, delta := range deltas {
+            send("content_block_delta", map[string]interface{}{"index": i, "delta": delta})
+        }
+        send("content_block_stop", map[string]interface{}{"index": i})
+    }
+    send("message_delta", map[string]interface{}{
+        "delta": map[string]interface{}{"stop_reason": resp.StopReason},
+        "usage": map[string]interface{}{"output_tokens": resp.Usage.OutputTokens},
+    })
+    send("message_stop", map[string]interface{}{})
+    if flusher, ok := w.(http.Flusher); ok {
+        flusher.Flush()
+    }
+}
+
+// errorBody returns an error in the API's format
+func errorBody(errorType, message string) map[string]interface{} {
+    return map[string]interface{}{
+        "type":  "error",
+        "error": map[string]string{"type": errorType, "message": message},
+    }
+}
+
+// writeError sends an error in the API's format
+func writeError(w http.ResponseWriter, status int, errorType, message string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(errorBody(errorType, message))
+}
+
+// TextResponse returns a response that ends the turn with text
+func TextResponse(text string) types.AnthropicResponse {
+    return types.AnthropicResponse{
+        ID:         "msg_test",
+        Type:       "message",
+        Role:       types.RoleAssistant,
+        Content:    []types.MessageContent{{Type: types.ContentTypeText, Text: text}},
+        StopReason: types.StopReasonEndTurn,
+        Usage:      types.Usage{InputTokens: 10, OutputTokens: 10},
+    }
+}
+
+// ToolUseResponse returns a response that calls the named tool with input,
+// which is encoded as JSON
+func ToolUseResponse(id, name string, input interface{}) types.AnthropicResponse {
+    data, err := json.Marshal(input)
+    if err != nil {
+        panic(fmt.Sprintf("anthropictest: encoding tool input: %v", err))
+    }
+    return types.AnthropicResponse{
+        ID:   "msg_test",
+        Type: "message",
+        Role: types.RoleAssistant,
+        Content: []types.MessageContent{{
+            Type:  types.ContentTypeToolUse,
+            ID:    id,
+            Name:  name,
+            Input: data,
+        }},
+        StopReason: types.StopReasonToolUse,
+        Usage:      types.Usage{InputTokens: 10, OutputTokens: 10},
+    }
+}
+
+// lastRequest returns the most recent request or fails the test
+func (s *Server) lastRequest(t testing.TB) types.Request {
+    t.Helper()
+    req, ok := s.LastRequest()
+    if !ok {
+        t.Fatal("anthropictest: no request received")
+    }
+    return req
+}
+
+// AssertModel fails the test unless the last request used model
+func (s *Server) AssertModel(t testing.TB, model string) {
+    t.Helper()
+    if req := s.lastRequest(t); req.Model != model {
+        t.Errorf("anthropictest: model = %q, want %q", req.Model, model)
+    }
+}
+
+// AssertMessages fails the test unless the last request carried messages
+// with the given roles, in order
+func (s *Server) AssertMessages(t testing.TB, roles ...string) {
+    t.Helper()
+    req := s.lastRequest(t)
+    got := make([]string, len(req.Messages))
+    for i, msg := range req.Messages {
+        got[i] = msg.Role
+    }
+    if strings.Join(got, ",") != strings.Join(roles, ",") {
+        t.Errorf("anthropictest: message roles = %v, want %v", got, roles)
+    }
+}
+
+// AssertTools fails the test unless the last request offered exactly the
+// named tools, in order
+func (s *Server) AssertTools(t testing.TB, names ...string) {
+    t.Helper()
+    req := s.lastRequest(t)
+    got := make([]string, len(req.Tools))
+    for i, tool := range req.Tools {
+        got[i] = tool.Name
+    }
+    if strings.Join(got, ",") != strings.Join(names, ",") {
+        t.Errorf("anthropictest: tools = %v, want %v", got, names)
+    }
+}
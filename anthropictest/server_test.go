@@ -0,0 +1,143 @@
+package anthropictest_test
+
+import (
+    "context"
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestServerAnswersMessagesInOrder(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("one"), anthropictest.TextResponse("two"))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+
+    for _, want := range []string{"one", "two"} {
+        resp, err := client.ChatMe(context.Background(), "Hi", nil)
+        if err != nil {
+            t.Fatalf("ChatMe: %v", err)
+        }
+        if got := resp.Content[0].Text; got != want {
+            t.Errorf("reply = %q, want %q", got, want)
+        }
+    }
+    srv.AssertModel(t, "claude-3-5-sonnet-20241022")
+    srv.AssertMessages(t, types.RoleUser, types.RoleAssistant, types.RoleUser)
+    if got := len(srv.Requests()); got != 2 {
+        t.Errorf("recorded %d requests, want 2", got)
+    }
+
+    if _, err := client.ChatMe(context.Background(), "Hi", nil); err == nil {
+        t.Error("expected an error once the canned responses run out")
+    }
+}
+
+func TestServerEnqueueError(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.EnqueueError(http.StatusTooManyRequests, "rate_limit_error", "slow down")
+    srv.Enqueue(anthropictest.TextResponse("ok"))
+    client := srv.Client()
+
+    _, err := client.ChatMe(context.Background(), "Hi", nil)
+    if !goanthropic.IsRateLimited(err) {
+        t.Fatalf("err = %v, want a rate limit error", err)
+    }
+    if _, err := client.ChatMe(context.Background(), "Hi", nil); err != nil {
+        t.Fatalf("ChatMe after the error: %v", err)
+    }
+}
+
+func TestServerEnqueueRaw(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.EnqueueRaw(http.StatusOK, `{"id":"msg_test","content":[{"type":"te`)
+
+    if _, err := srv.Client().ChatMe(context.Background(), "Hi", nil); err == nil {
+        t.Fatal("expected an error for a truncated body")
+    }
+}
+
+func TestServerStreamsResponses(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("Hello there"))
+    defer srv.Close()
+
+    events, err := srv.Client().ChatStream(context.Background(), "Hi", nil)
+    if err != nil {
+        t.Fatalf("ChatStream: %v", err)
+    }
+    var text strings.Builder
+    var final *types.AnthropicResponse
+    for event := range events {
+        switch event.Type {
+        case types.StreamEventText:
+            text.WriteString(event.Text)
+        case types.StreamEventMessageStop:
+            final = event.Response
+        case types.StreamEventError:
+            t.Fatalf("stream error: %v", event.Err)
+        }
+    }
+    if text.String() != "Hello there" {
+        t.Errorf("streamed text = %q, want %q", text.String(), "Hello there")
+    }
+    if final == nil || final.StopReason != types.StopReasonEndTurn {
+        t.Errorf("final response = %+v, want stop reason %q", final, types.StopReasonEndTurn)
+    }
+}
+
+func TestServerCountsTokens(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.SetTokenCounter(func(req types.CountTokensRequest) int { return 42 })
+
+    count, err := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022")).CountTokens(context.Background(), &types.MessageParams{
+        System:   "Be brief",
+        Messages: []types.Message{{Role: types.RoleUser, Content: []types.MessageContent{{Type: types.ContentTypeText, Text: "Hi"}}}},
+    })
+    if err != nil {
+        t.Fatalf("CountTokens: %v", err)
+    }
+    if count != 42 {
+        t.Errorf("count = %d, want 42", count)
+    }
+    reqs := srv.CountRequests()
+    if len(reqs) != 1 || reqs[0].System != "Be brief" {
+        t.Errorf("count requests = %+v, want one with the system prompt", reqs)
+    }
+}
+
+func TestServerListsModels(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.SetModels(
+        types.Model{ID: "claude-sonnet-4-5-20250929", Type: "model"},
+        types.Model{ID: "claude-3-5-haiku-20241022", Type: "model"},
+    )
+
+    models, err := srv.Client().ListModels(context.Background())
+    if err != nil {
+        t.Fatalf("ListModels: %v", err)
+    }
+    if len(models) != 2 || models[0].ID != "claude-sonnet-4-5-20250929" {
+        t.Errorf("models = %+v", models)
+    }
+}
+
+func TestServerRejectsBatches(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+
+    resp, err := http.Post(srv.URL+"/v1/messages/batches", "application/json", strings.NewReader("{}"))
+    if err != nil {
+        t.Fatalf("POST: %v", err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusNotFound {
+        t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+    }
+}
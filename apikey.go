@@ -0,0 +1,27 @@
+package goanthropic
+
+import (
+    "context"
+    "strings"
+)
+
+// apiKeyContextKey is the context key of a per-request API key
+type apiKeyContextKey struct{}
+
+// ContextWithAPIKey returns a copy of ctx whose requests use key in place of
+// the client's API key, so a multi-tenant service can share one client and
+// its connection pool between keys. Pass the returned context to any client
+// call. The key is only placed in the x-api-key header and is never logged.
+// Results cached by the client, such as the model list kept by
+// WithModelListCache, are shared by all keys.
+func ContextWithAPIKey(ctx context.Context, key string) context.Context {
+    return context.WithValue(ctx, apiKeyContextKey{}, strings.TrimSpace(key))
+}
+
+// requestAPIKey returns the API key for a request sent with ctx
+func (c *AnthropicClient) requestAPIKey(ctx context.Context) string {
+    if key, ok := ctx.Value(apiKeyContextKey{}).(string); ok && key != "" {
+        return key
+    }
+    return c.apiKey
+}
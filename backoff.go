@@ -0,0 +1,96 @@
+package goanthropic
+
+import (
+    "math/rand"
+    "time"
+)
+
+// BackoffCurve is how the delay between retries grows with each attempt
+type BackoffCurve int
+
+const (
+    // BackoffConstant waits Base before every retry (the default)
+    BackoffConstant BackoffCurve = iota
+    // BackoffLinear waits Base, 2*Base, 3*Base, ...
+    BackoffLinear
+    // BackoffExponential waits Base, 2*Base, 4*Base, ...
+    BackoffExponential
+)
+
+// JitterMode is how much randomness is applied to a retry delay
+type JitterMode int
+
+const (
+    // JitterNone uses the computed delay exactly (the default)
+    JitterNone JitterMode = iota
+    // JitterFull waits a random time between zero and the computed delay
+    JitterFull
+    // JitterEqual waits half the computed delay plus a random time up to the other half
+    JitterEqual
+)
+
+// BackoffStrategy describes the delay before each retry; see WithBackoff. A
+// zero Base uses the default delay of 500ms and a zero Max leaves the delay
+// uncapped. The cap is applied before jitter, so a full-jitter strategy
+// capped at 30 seconds never waits longer than 30 seconds.
+type BackoffStrategy struct {
+    Curve  BackoffCurve
+    Base   time.Duration
+    Max    time.Duration
+    Jitter JitterMode
+}
+
+// defaultBackoff waits a constant malformedRetryDelay between retries
+var defaultBackoff = BackoffStrategy{Curve: BackoffConstant, Base: malformedRetryDelay}
+
+// maxBackoffDelay bounds uncapped curves so the delay cannot overflow
+const maxBackoffDelay = time.Hour
+
+// WithBackoff sets the delay curve used between retries: those of rate limit,
+// overload and server errors enabled with WithRetries, and those of malformed
+// responses enabled with WithMalformedResponseRetries. Strategies with a
+// negative Base or Max are ignored.
+func WithBackoff(strategy BackoffStrategy) ClientOption {
+    return func(c *AnthropicClient) {
+        if strategy.Base < 0 || strategy.Max < 0 {
+            return
+        }
+        if strategy.Base == 0 {
+            strategy.Base = malformedRetryDelay
+        }
+        c.backoff = strategy
+    }
+}
+
+// Delay returns the wait before retry number attempt, counting from zero
+func (b BackoffStrategy) Delay(attempt int) time.Duration {
+    base := b.Base
+    if base <= 0 {
+        base = malformedRetryDelay
+    }
+
+    delay := base
+    switch b.Curve {
+    case BackoffLinear:
+        delay = base * time.Duration(attempt+1)
+    case BackoffExponential:
+        for i := 0; i < attempt && delay < maxBackoffDelay/2; i++ {
+            delay *= 2
+        }
+    }
+    if delay <= 0 || delay > maxBackoffDelay {
+        delay = maxBackoffDelay
+    }
+    if b.Max > 0 && delay > b.Max {
+        delay = b.Max
+    }
+
+    switch b.Jitter {
+    case JitterFull:
+        delay = time.Duration(rand.Int63n(int64(delay) + 1))
+    case JitterEqual:
+        half := delay / 2
+        delay = half + time.Duration(rand.Int63n(int64(delay-half)+1))
+    }
+    return delay
+}
@@ -0,0 +1,98 @@
+package goanthropic_test
+
+import (
+    "context"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+)
+
+func TestBackoffDelay(t *testing.T) {
+    tests := []struct {
+        name     string
+        strategy goanthropic.BackoffStrategy
+        want     []time.Duration
+    }{
+        {"default", goanthropic.BackoffStrategy{}, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond}},
+        {"constant", goanthropic.BackoffStrategy{Base: time.Second}, []time.Duration{time.Second, time.Second, time.Second}},
+        {"linear", goanthropic.BackoffStrategy{Curve: goanthropic.BackoffLinear, Base: time.Second}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+        {"exponential", goanthropic.BackoffStrategy{Curve: goanthropic.BackoffExponential, Base: time.Second}, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}},
+        {"capped", goanthropic.BackoffStrategy{Curve: goanthropic.BackoffExponential, Base: time.Second, Max: 3 * time.Second}, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}},
+    }
+    for _, tt := range tests {
+        for attempt, want := range tt.want {
+            if got := tt.strategy.Delay(attempt); got != want {
+                t.Errorf("%s: Delay(%d) = %v, want %v", tt.name, attempt, got, want)
+            }
+        }
+    }
+}
+
+func TestBackoffFullJitterStaysUnderCap(t *testing.T) {
+    strategy := goanthropic.BackoffStrategy{
+        Curve:  goanthropic.BackoffExponential,
+        Base:   time.Second,
+        Max:    30 * time.Second,
+        Jitter: goanthropic.JitterFull,
+    }
+    for attempt := 0; attempt < 100; attempt++ {
+        if got := strategy.Delay(attempt); got < 0 || got > 30*time.Second {
+            t.Fatalf("Delay(%d) = %v, want between 0 and 30s", attempt, got)
+        }
+    }
+
+    // Huge attempt counts must not overflow into a negative delay
+    uncapped := goanthropic.BackoffStrategy{Curve: goanthropic.BackoffExponential, Base: time.Second}
+    if got := uncapped.Delay(1000); got <= 0 {
+        t.Errorf("Delay(1000) = %v, want a positive delay", got)
+    }
+}
+
+func TestRetriesUseBackoff(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.EnqueueError(http.StatusTooManyRequests, "rate_limit_error", "slow down")
+    srv.EnqueueError(529, "overloaded_error", "busy")
+    srv.EnqueueError(http.StatusInternalServerError, "api_error", "oops")
+    srv.Enqueue(anthropictest.TextResponse("Hello"))
+    client := srv.Client(
+        goanthropic.WithModel("claude-3-5-sonnet-20241022"),
+        goanthropic.WithRetries(3),
+        goanthropic.WithBackoff(goanthropic.BackoffStrategy{Curve: goanthropic.BackoffExponential, Base: time.Millisecond}),
+    )
+
+    response, err := client.ChatMe(context.Background(), "Hi", nil)
+    if err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if response.Text() != "Hello" || len(srv.Requests()) != 4 {
+        t.Errorf("got %q after %d requests, want Hello after 4", response.Text(), len(srv.Requests()))
+    }
+
+    // Client errors are not retried, and the retry budget is per request
+    srv.EnqueueError(http.StatusBadRequest, "invalid_request_error", "bad")
+    if _, err := client.ChatMe(context.Background(), "Hi", nil); err == nil {
+        t.Fatal("ChatMe succeeded after a 400")
+    }
+    if got := len(srv.Requests()); got != 5 {
+        t.Errorf("sent %d requests, want the 400 sent once", got)
+    }
+}
+
+func TestRetriesOffByDefault(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.EnqueueError(http.StatusTooManyRequests, "rate_limit_error", "slow down")
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+
+    _, err := client.ChatMe(context.Background(), "Hi", nil)
+    if !goanthropic.IsRateLimited(err) {
+        t.Fatalf("err = %v, want a rate limit error", err)
+    }
+    if got := len(srv.Requests()); got != 1 {
+        t.Errorf("sent %d requests, want 1", got)
+    }
+}
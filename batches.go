@@ -0,0 +1,150 @@
+package goanthropic
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/url"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// maxBatchResultLine is the longest single result accepted from a results file
+const maxBatchResultLine = 32 * 1024 * 1024
+
+// batchEntry is the wire form of a BatchRequest
+type batchEntry struct {
+    CustomID string        `json:"custom_id"`
+    Params   types.Request `json:"params"`
+}
+
+// SubmitBatch creates a message batch that the API processes asynchronously
+// at a reduced price. Each entry is merged with the client defaults and must
+// carry its own messages; the client conversation is neither used nor changed.
+// Poll GetBatch until the batch has ended, then fetch GetBatchResults.
+func (c *AnthropicClient) SubmitBatch(ctx context.Context, requests []types.BatchRequest) (*types.Batch, error) {
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    if len(requests) == 0 {
+        return nil, fmt.Errorf("batch must contain at least one request")
+    }
+
+    seen := make(map[string]bool, len(requests))
+    entries := make([]batchEntry, 0, len(requests))
+    for i, request := range requests {
+        if request.CustomID == "" {
+            return nil, fmt.Errorf("batch request %d: custom_id is required", i)
+        }
+        if seen[request.CustomID] {
+            return nil, fmt.Errorf("batch request %d: duplicate custom_id %q", i, request.CustomID)
+        }
+        seen[request.CustomID] = true
+        if len(request.Params.Messages) == 0 {
+            return nil, fmt.Errorf("batch request %q: at least one message is required", request.CustomID)
+        }
+
+        params := c.mergeParams(&request.Params)
+        reqBody, err := c.applyRequestSettings(types.Request{
+            Model:         params.Model,
+            System:        params.System,
+            SystemBlocks:  params.SystemBlocks,
+            Messages:      request.Params.Messages,
+            MaxTokens:     params.MaxTokens,
+            Temperature:   params.Temperature,
+            TopP:          params.TopP,
+            TopK:          params.TopK,
+            StopSequences: params.StopSequences,
+            Metadata:      params.Metadata,
+            Tools:         c.orderedTools(params.Tools),
+            ToolChoice:    params.ToolChoice,
+            Thinking:      params.Thinking,
+        })
+        if err != nil {
+            return nil, fmt.Errorf("batch request %q: %w", request.CustomID, err)
+        }
+        entries = append(entries, batchEntry{CustomID: request.CustomID, Params: reqBody})
+    }
+
+    c.logMessage("Submitting batch of %d requests", len(entries))
+    body, err := c.postJSON(ctx, c.endpoint(batchesPath), map[string]interface{}{"requests": entries}, nil)
+    if err != nil {
+        c.recordError(err)
+        return nil, err
+    }
+    return c.parseBatch(body)
+}
+
+// GetBatch returns the current status of a message batch
+func (c *AnthropicClient) GetBatch(ctx context.Context, id string) (*types.Batch, error) {
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    if id == "" {
+        return nil, fmt.Errorf("batch id is required")
+    }
+    body, err := c.getJSON(ctx, c.endpoint(batchesPath)+"/"+url.PathEscape(id))
+    if err != nil {
+        c.recordError(err)
+        return nil, err
+    }
+    return c.parseBatch(body)
+}
+
+// GetBatchResults downloads and parses the results of an ended batch. Results
+// are not guaranteed to be in submission order; match them by CustomID. They
+// are fetched from the configured base URL rather than the batch's
+// results_url, so gateways and test servers see the request.
+func (c *AnthropicClient) GetBatchResults(ctx context.Context, id string) ([]types.BatchResult, error) {
+    batch, err := c.GetBatch(ctx, id)
+    if err != nil {
+        return nil, err
+    }
+    if batch.ProcessingStatus != types.BatchStatusEnded || batch.ResultsURL == "" {
+        return nil, fmt.Errorf("batch %s has not ended (status %s)", id, batch.ProcessingStatus)
+    }
+
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    body, err := c.getJSON(ctx, c.endpoint(batchesPath)+"/"+url.PathEscape(id)+"/results")
+    if err != nil {
+        c.recordError(err)
+        return nil, err
+    }
+    return parseBatchResults(body)
+}
+
+// parseBatch decodes a batch object
+func (c *AnthropicClient) parseBatch(body []byte) (*types.Batch, error) {
+    var batch types.Batch
+    if err := json.Unmarshal(body, &batch); err != nil {
+        c.logError("Error parsing batch response: %v", err)
+        return nil, fmt.Errorf("error parsing response: %w", err)
+    }
+    return &batch, nil
+}
+
+// parseBatchResults decodes a JSONL results file, one result per line
+func parseBatchResults(body []byte) ([]types.BatchResult, error) {
+    var results []types.BatchResult
+    scanner := bufio.NewScanner(bytes.NewReader(body))
+    scanner.Buffer(make([]byte, 0, 64*1024), maxBatchResultLine)
+    for line := 1; scanner.Scan(); line++ {
+        data := bytes.TrimSpace(scanner.Bytes())
+        if len(data) == 0 {
+            continue
+        }
+        var result types.BatchResult
+        if err := json.Unmarshal(data, &result); err != nil {
+            return results, fmt.Errorf("error parsing batch result line %d: %w", line, err)
+        }
+        results = append(results, result)
+    }
+    if err := scanner.Err(); err != nil {
+        return results, fmt.Errorf("error reading batch results: %w", err)
+    }
+    return results, nil
+}
@@ -0,0 +1,41 @@
+package goanthropic_test
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestGetBatchResultsUsesBatchesEndpoint(t *testing.T) {
+    var paths []string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        paths = append(paths, r.URL.Path)
+        switch r.URL.Path {
+        case "/v1/messages/batches/batch_1":
+            fmt.Fprintf(w, `{"id": "batch_1", "processing_status": %q, "results_url": "https://files.example.com/batch_1.jsonl"}`, types.BatchStatusEnded)
+        case "/v1/messages/batches/batch_1/results":
+            fmt.Fprintln(w, `{"custom_id": "a", "result": {"type": "succeeded"}}`)
+            fmt.Fprintln(w, `{"custom_id": "b", "result": {"type": "errored"}}`)
+        default:
+            http.NotFound(w, r)
+        }
+    }))
+    defer srv.Close()
+    client := goanthropic.NewClient("test-key", goanthropic.WithBaseURL(srv.URL))
+
+    results, err := client.GetBatchResults(context.Background(), "batch_1")
+    if err != nil {
+        t.Fatalf("GetBatchResults: %v (requested %v)", err, paths)
+    }
+    if len(results) != 2 || results[0].CustomID != "a" || results[1].Result.Type != types.BatchResultErrored {
+        t.Errorf("results = %+v", results)
+    }
+    if len(paths) != 2 || paths[1] != "/v1/messages/batches/batch_1/results" {
+        t.Errorf("requested %v, want the results under the batches endpoint", paths)
+    }
+}
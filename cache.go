@@ -0,0 +1,244 @@
+package goanthropic
+
+import (
+    "crypto/sha256"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "sort"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// extendedCacheTTLBeta enables cache entries that live for one hour
+const extendedCacheTTLBeta = "extended-cache-ttl-2025-04-11"
+
+// WithSystemPromptCache marks the system prompt as cacheable on every request,
+// so a large, stable prompt is billed at the cache read rate after the first
+// turn. ttl may be empty for the default lifetime, CacheTTL5m or CacheTTL1h.
+func WithSystemPromptCache(ttl string) ClientOption {
+    return func(c *AnthropicClient) {
+        c.systemCache = types.EphemeralCache(ttl)
+    }
+}
+
+// WithToolCache marks the named tool as cacheable on every request that
+// offers it. Tools are sent before the system prompt, so marking the last
+// tool caches the whole tool list.
+func WithToolCache(name, ttl string) ClientOption {
+    return func(c *AnthropicClient) {
+        if c.toolCache == nil {
+            c.toolCache = make(map[string]*types.CacheControl)
+        }
+        c.toolCache[name] = types.EphemeralCache(ttl)
+    }
+}
+
+// applyCacheMarkers adds the configured system prompt and tool cache markers
+// to a request. With system blocks the marker goes on the last block, unless
+// a block is already marked. Tools and blocks are copied so the caller's
+// definitions are not modified.
+func (c *AnthropicClient) applyCacheMarkers(req types.Request) types.Request {
+    if c.systemCache != nil && len(req.SystemBlocks) > 0 && !systemBlocksCached(req.SystemBlocks) {
+        blocks := append([]types.SystemBlock(nil), req.SystemBlocks...)
+        blocks[len(blocks)-1].CacheControl = c.systemCache
+        req.SystemBlocks = blocks
+    } else if c.systemCache != nil && req.SystemCacheControl == nil {
+        req.SystemCacheControl = c.systemCache
+    }
+    if len(c.toolCache) == 0 {
+        return req
+    }
+
+    tools := make([]types.Tool, len(req.Tools))
+    for i, tool := range req.Tools {
+        if cc, ok := c.toolCache[tool.Name]; ok && tool.CacheControl == nil {
+            tool.CacheControl = cc
+        }
+        tools[i] = tool
+    }
+    req.Tools = tools
+    return req
+}
+
+// systemBlocksCached reports whether any system block carries a cache marker
+func systemBlocksCached(blocks []types.SystemBlock) bool {
+    for _, block := range blocks {
+        if block.CacheControl != nil {
+            return true
+        }
+    }
+    return false
+}
+
+// validateCacheControl rejects cache markers with an unknown type or TTL
+func validateCacheControl(req types.Request) error {
+    return forEachCacheControl(req, func(location string, cc *types.CacheControl) error {
+        if cc.Type != types.CacheControlEphemeral {
+            return fmt.Errorf("%s: unsupported cache_control type %q", location, cc.Type)
+        }
+        switch cc.TTL {
+        case "", types.CacheTTL5m, types.CacheTTL1h:
+            return nil
+        default:
+            return fmt.Errorf("%s: unsupported cache TTL %q (use %q or %q)",
+                location, cc.TTL, types.CacheTTL5m, types.CacheTTL1h)
+        }
+    })
+}
+
+// requestBetas returns the beta features a request needs based on its content
+func requestBetas(req types.Request) []string {
+    var betas []string
+    if hasDocuments(req.Messages) {
+        betas = append(betas, pdfBeta)
+    }
+    forEachCacheControl(req, func(location string, cc *types.CacheControl) error {
+        if cc.TTL == types.CacheTTL1h {
+            betas = append(betas, extendedCacheTTLBeta)
+            return errStopWalk
+        }
+        return nil
+    })
+    return betas
+}
+
+// errStopWalk ends a walk early without reporting an error
+var errStopWalk = errors.New("stop walk")
+
+// forEachCacheControl calls fn for every cache marker in the request
+func forEachCacheControl(req types.Request, fn func(location string, cc *types.CacheControl) error) error {
+    if len(req.SystemBlocks) > 0 {
+        for i, block := range req.SystemBlocks {
+            if block.CacheControl != nil {
+                if err := fn(fmt.Sprintf("system block %d", i), block.CacheControl); err != nil {
+                    return stopWalkErr(err)
+                }
+            }
+        }
+    } else if req.SystemCacheControl != nil && req.System != "" {
+        if err := fn("system", req.SystemCacheControl); err != nil {
+            return stopWalkErr(err)
+        }
+    }
+    for _, tool := range req.Tools {
+        if tool.CacheControl != nil {
+            if err := fn(fmt.Sprintf("tool %s", tool.Name), tool.CacheControl); err != nil {
+                return stopWalkErr(err)
+            }
+        }
+    }
+    for i, msg := range req.Messages {
+        for j, block := range msg.Content {
+            if block.CacheControl != nil {
+                if err := fn(fmt.Sprintf("message %d block %d", i, j), block.CacheControl); err != nil {
+                    return stopWalkErr(err)
+                }
+            }
+        }
+    }
+    return nil
+}
+
+// stopWalkErr converts the early-exit marker into a nil error
+func stopWalkErr(err error) error {
+    if err == errStopWalk {
+        return nil
+    }
+    return err
+}
+
+// blockObservation tracks the content of one prompt position across requests
+type blockObservation struct {
+    order    int
+    hash     [sha256.Size]byte
+    requests int
+    stable   bool
+    cached   bool
+    size     int
+}
+
+// AnalyzeCacheability reports, for the system prompt, each tool and each
+// message position, whether its content has been identical on every request
+// this client has sent. Stable blocks that are not yet cached are good
+// candidates for cache_control markers. Message positions shift when the
+// conversation is trimmed, which correctly shows them as unstable.
+func (c *AnthropicClient) AnalyzeCacheability() []types.BlockStability {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    observations := make([]*blockObservation, 0, len(c.cacheObservations))
+    labels := make(map[*blockObservation]string, len(c.cacheObservations))
+    for label, obs := range c.cacheObservations {
+        observations = append(observations, obs)
+        labels[obs] = label
+    }
+    sort.Slice(observations, func(i, j int) bool {
+        return observations[i].order < observations[j].order
+    })
+
+    report := make([]types.BlockStability, 0, len(observations))
+    for _, obs := range observations {
+        report = append(report, types.BlockStability{
+            Block:           labels[obs],
+            Requests:        obs.requests,
+            Stable:          obs.stable,
+            Cached:          obs.cached,
+            EstimatedTokens: obs.size / 4,
+        })
+    }
+    return report
+}
+
+// observeCacheability records the content of each prompt block in a request
+func (c *AnthropicClient) observeCacheability(req types.Request) {
+    c = c.root()
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if c.cacheObservations == nil {
+        c.cacheObservations = make(map[string]*blockObservation)
+    }
+
+    if req.System != "" {
+        c.observeBlock("system", req.System, req.SystemCacheControl != nil || systemBlocksCached(req.SystemBlocks))
+    }
+    for _, tool := range req.Tools {
+        c.observeBlock("tool:"+tool.Name, tool, tool.CacheControl != nil)
+    }
+    for i, msg := range req.Messages {
+        cached := false
+        for _, block := range msg.Content {
+            cached = cached || block.CacheControl != nil
+        }
+        c.observeBlock(fmt.Sprintf("message:%d", i), msg, cached)
+    }
+}
+
+// observeBlock updates the observation for a single labelled block. The caller must hold c.mu.
+func (c *AnthropicClient) observeBlock(label string, content interface{}, cached bool) {
+    data, err := json.Marshal(content)
+    if err != nil {
+        return
+    }
+    hash := sha256.Sum256(data)
+
+    obs, ok := c.cacheObservations[label]
+    if !ok {
+        c.cacheObservations[label] = &blockObservation{
+            order:    len(c.cacheObservations),
+            hash:     hash,
+            requests: 1,
+            stable:   true,
+            cached:   cached,
+            size:     len(data),
+        }
+        return
+    }
+
+    obs.requests++
+    obs.stable = obs.stable && obs.hash == hash
+    obs.hash = hash
+    obs.cached = cached
+    obs.size = len(data)
+}
@@ -0,0 +1,139 @@
+package goanthropic_test
+
+import (
+    "context"
+    "net/http"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// headerRecorder is a middleware that keeps the headers of every request
+type headerRecorder struct {
+    mu      sync.Mutex
+    headers []http.Header
+}
+
+func (h *headerRecorder) middleware(next http.RoundTripper) http.RoundTripper {
+    return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+        h.mu.Lock()
+        h.headers = append(h.headers, req.Header.Clone())
+        h.mu.Unlock()
+        return next.RoundTrip(req)
+    })
+}
+
+func (h *headerRecorder) last() http.Header {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    return h.headers[len(h.headers)-1]
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+    return f(req)
+}
+
+func TestSystemPromptCacheTTL(t *testing.T) {
+    tests := []struct {
+        ttl      string
+        wantBeta bool
+    }{
+        {"", false},
+        {types.CacheTTL5m, false},
+        {types.CacheTTL1h, true},
+    }
+    for _, tt := range tests {
+        srv := anthropictest.NewServer(anthropictest.TextResponse("Hello"))
+        recorder := &headerRecorder{}
+        client := srv.Client(
+            goanthropic.WithSystemPrompt("You are terse."),
+            goanthropic.WithSystemPromptCache(tt.ttl),
+            goanthropic.WithMiddleware(recorder.middleware),
+        )
+
+        if _, err := client.ChatMe(context.Background(), "Hi", nil); err != nil {
+            t.Fatalf("ttl %q: ChatMe: %v", tt.ttl, err)
+        }
+
+        req, _ := srv.LastRequest()
+        if len(req.SystemBlocks) != 1 || req.SystemBlocks[0].CacheControl == nil {
+            t.Fatalf("ttl %q: system sent as %+v, want one cached block", tt.ttl, req.SystemBlocks)
+        }
+        if got := req.SystemBlocks[0].CacheControl.TTL; got != tt.ttl {
+            t.Errorf("ttl %q: sent ttl %q", tt.ttl, got)
+        }
+        beta := recorder.last().Get("anthropic-beta")
+        if got := strings.Contains(beta, "extended-cache-ttl-2025-04-11"); got != tt.wantBeta {
+            t.Errorf("ttl %q: anthropic-beta = %q, want extended cache beta %v", tt.ttl, beta, tt.wantBeta)
+        }
+        srv.Close()
+    }
+}
+
+func TestCacheTTLRejectsUnknownValue(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("Hello"))
+    defer srv.Close()
+    client := srv.Client(
+        goanthropic.WithSystemPrompt("You are terse."),
+        goanthropic.WithSystemPromptCache("2h"),
+    )
+
+    _, err := client.ChatMe(context.Background(), "Hi", nil)
+    if err == nil || !strings.Contains(err.Error(), `unsupported cache TTL "2h"`) {
+        t.Fatalf("err = %v, want an unsupported TTL error", err)
+    }
+    if n := len(srv.Requests()); n != 0 {
+        t.Errorf("sent %d requests, want none", n)
+    }
+}
+
+// stability returns the report entry for block, failing the test if there is none
+func stability(t *testing.T, report []types.BlockStability, block string) types.BlockStability {
+    t.Helper()
+    for _, entry := range report {
+        if entry.Block == block {
+            return entry
+        }
+    }
+    t.Fatalf("no entry for %q in %+v", block, report)
+    return types.BlockStability{}
+}
+
+func TestAnalyzeCacheabilityConstantSystemPrompt(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("one"), anthropictest.TextResponse("two"), anthropictest.TextResponse("three"))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithSystemPrompt(strings.Repeat("You are a careful assistant. ", 40)))
+
+    for _, message := range []string{"first", "second", "third"} {
+        if _, err := client.ChatMe(context.Background(), message, nil); err != nil {
+            t.Fatalf("ChatMe: %v", err)
+        }
+    }
+
+    report := client.AnalyzeCacheability()
+    system := stability(t, report, "system")
+    if system.Requests != 3 || !system.Stable || system.Cached {
+        t.Errorf("system = %+v, want stable and uncached over 3 requests", system)
+    }
+    if system.EstimatedTokens == 0 {
+        t.Error("system has no token estimate")
+    }
+    if first := stability(t, report, "message:0"); !first.Stable || first.Requests != 3 {
+        t.Errorf("message:0 = %+v, want stable over 3 requests", first)
+    }
+
+    client.SetSystemPrompt("You are terse.")
+    srv.Enqueue(anthropictest.TextResponse("four"))
+    if _, err := client.ChatMe(context.Background(), "fourth", nil); err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if system := stability(t, client.AnalyzeCacheability(), "system"); system.Stable {
+        t.Errorf("system = %+v, want unstable after it changed", system)
+    }
+}
@@ -11,7 +11,6 @@ import (
     "github.com/joho/godotenv"
     
     "github.com/rdhillbb/goanthropic"
-    "github.com/rdhillbb/goanthropic/types"
 )
 
 const defaultModel = "claude-3-5-sonnet-20241022"
@@ -41,14 +40,13 @@ func main() {
     }
 
     handlers := GetDefaultHandlers() // This now returns []types.ToolHandler
-    
+
+    params := goanthropic.NewToolParams(handlers...)
+    params.Model = defaultModel
+    params.MaxTokens = 7900
+
     client := goanthropic.NewClient(apiKey, 
-        goanthropic.WithDefaultParams(types.MessageParams{
-            Model:      defaultModel,
-            MaxTokens:  7900,
-            Tools:      GetDefaultTools(),
-            ToolChoice: &types.ToolChoice{Type: types.ToolChoiceAuto},
-        }),
+        goanthropic.WithDefaultParams(params),
         goanthropic.WithMaxConversationLength(1000),
     )
 
@@ -57,7 +55,7 @@ func main() {
 
     fmt.Println("Chat initialized with tools. Type 'exit' to quit.")
     fmt.Println("Available tools:")
-    for _, tool := range GetDefaultTools() {
+    for _, tool := range params.Tools {
         fmt.Printf("- %s: %s\n", tool.Name, tool.Description)
     }
     fmt.Println("\nEnter your message:")
@@ -90,11 +88,7 @@ func main() {
         }
 
         fmt.Println("\nAssistant:")
-        for _, content := range response.Content {
-            if content.Type == types.ContentTypeText {
-                fmt.Println(content.Text)
-            }
-        }
+        fmt.Println(response.Text())
         fmt.Println()
     }
 
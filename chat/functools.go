@@ -7,25 +7,11 @@ import (
     "github.com/rdhillbb/goanthropic/types"
 )
 
-// BaseToolHandler provides a base implementation of ToolHandler
-type BaseToolHandler struct {
-    tool types.Tool
-    handler func(context.Context, json.RawMessage) (string, error)
-}
-
-func (h *BaseToolHandler) Execute(ctx context.Context, input json.RawMessage) (string, error) {
-    return h.handler(ctx, input)
-}
-
-func (h *BaseToolHandler) GetTool() types.Tool {
-    return h.tool
-}
-
 // CreateToolHandler creates a new ToolHandler with the given tool and handler function
 func CreateToolHandler(tool types.Tool, handler func(context.Context, json.RawMessage) (string, error)) types.ToolHandler {
-    return &BaseToolHandler{
-        tool: tool,
-        handler: handler,
+    return types.ToolHandlerFunc{
+        Tool: tool,
+        Func: handler,
     }
 }
 
@@ -0,0 +1,121 @@
+package goanthropic
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "strings"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// compactPrompt asks the model to summarize the transcript that follows it
+const compactPrompt = "Summarize the conversation below so that it can replace the original messages. " +
+    "Keep every fact, decision, name, number and open question needed to continue the conversation. " +
+    "Reply with the summary only.\n\n"
+
+// compactSummaryPrefix introduces the summary stored in place of the compacted messages
+const compactSummaryPrefix = "Summary of the earlier conversation:\n"
+
+// CompactConversation replaces the oldest stored messages with a summary
+// written by the model, keeping at least the keepRecent most recent messages
+// verbatim. This preserves far more context than trimming. params is merged
+// with the client defaults; tools are not offered to the summarizing request.
+//
+// The summary is added as a text block at the start of the first kept user
+// message, so the history still begins with a user turn and no tool_use is
+// separated from its tool_result. Nothing is changed when there is no safe
+// point to split the conversation before the kept messages.
+//
+// The summarizing request is never compacted itself. When it is rejected as
+// too large the conversation is left unchanged and the *RequestTooLargeError
+// is returned; raise keepRecent to summarize fewer messages at a time.
+func (c *AnthropicClient) CompactConversation(ctx context.Context, keepRecent int, params *types.MessageParams) error {
+    if keepRecent < 0 {
+        return fmt.Errorf("keepRecent cannot be negative")
+    }
+
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    messages := c.conversationSnapshot()
+    if keepRecent >= len(messages) {
+        return nil
+    }
+    split := safeStartIndex(messages, len(messages)-keepRecent)
+    if split >= len(messages) && keepRecent > 0 {
+        c.logMessage("No safe point to compact the conversation before the last %d messages", keepRecent)
+        return nil
+    }
+
+    finalParams := c.mergeParams(params)
+    reqBody := types.Request{
+        Model:        finalParams.Model,
+        System:       finalParams.System,
+        SystemBlocks: finalParams.SystemBlocks,
+        Messages: []types.Message{{
+            Role:    types.RoleUser,
+            Content: []types.MessageContent{{Type: types.ContentTypeText, Text: compactPrompt + transcript(messages[:split])}},
+        }},
+        MaxTokens:   finalParams.MaxTokens,
+        Temperature: finalParams.Temperature,
+        Metadata:    finalParams.Metadata,
+    }
+
+    response, err := c.sendRequest(ctx, reqBody)
+    var tooLarge *RequestTooLargeError
+    if errors.As(err, &tooLarge) {
+        return fmt.Errorf("error summarizing conversation: %d messages are too large to summarize in one request: %w", split, err)
+    }
+    if err != nil {
+        return fmt.Errorf("error summarizing conversation: %w", err)
+    }
+    summary := strings.TrimSpace(response.Text())
+    if summary == "" {
+        return fmt.Errorf("error summarizing conversation: %w", ErrEmptyResponse)
+    }
+
+    c.mu.Lock()
+    defer c.unlock()
+    if len(c.conversation) < split || c.conversation[split-1].CreatedAt != messages[split-1].CreatedAt {
+        return fmt.Errorf("conversation changed while it was being summarized")
+    }
+
+    c.logMessage("Compacted %d messages into a summary", split)
+    block := types.MessageContent{Type: types.ContentTypeText, Text: compactSummaryPrefix + summary}
+    kept := c.conversation[split:]
+    c.conversation = append([]types.Message(nil), kept...)
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventTrim, Removed: split})
+    if len(c.conversation) == 0 {
+        c.appendMessage(types.RoleUser, []types.MessageContent{block})
+        return nil
+    }
+    first := &c.conversation[0]
+    first.Content = append([]types.MessageContent{block}, first.Content...)
+    c.notifyMessageChange(types.ConversationEventEdit, *first)
+    return nil
+}
+
+// transcript renders messages as plain text for summarization
+func transcript(messages []types.Message) string {
+    var text strings.Builder
+    for _, msg := range messages {
+        for _, block := range msg.Content {
+            switch block.Type {
+            case types.ContentTypeText:
+                fmt.Fprintf(&text, "%s: %s\n", msg.Role, block.Text)
+            case types.ContentTypeToolUse:
+                fmt.Fprintf(&text, "%s called tool %s with input %s\n", msg.Role, block.Name, block.Input)
+            case types.ContentTypeToolResult:
+                result := block.Content
+                if block.ContentBlocks != nil {
+                    result = types.ToolResult{Blocks: block.ContentBlocks}.Text()
+                }
+                fmt.Fprintf(&text, "tool result: %s\n", result)
+            case types.ContentTypeImage, types.ContentTypeDocument:
+                fmt.Fprintf(&text, "%s: [%s]\n", msg.Role, block.Type)
+            }
+        }
+    }
+    return text.String()
+}
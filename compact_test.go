@@ -0,0 +1,62 @@
+package goanthropic_test
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "reflect"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestCompactConversation(t *testing.T) {
+    srv := anthropictest.NewServer(
+        anthropictest.TextResponse("a"),
+        anthropictest.TextResponse("b"),
+        anthropictest.TextResponse("c"),
+        anthropictest.TextResponse("We said one and two."),
+    )
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+    chatTurns(t, client, "one", "two", "three")
+
+    if err := client.CompactConversation(context.Background(), 2, nil); err != nil {
+        t.Fatalf("CompactConversation: %v", err)
+    }
+    conversation := client.GetConversation()
+    if len(conversation) != 2 {
+        t.Fatalf("conversation has %d messages, want 2", len(conversation))
+    }
+    first := conversation[0]
+    if first.Role != types.RoleUser || !strings.Contains(first.Content[0].Text, "We said one and two.") {
+        t.Errorf("first message = %+v, want the summary in a user turn", first)
+    }
+    if first.Content[1].Text != "three" {
+        t.Errorf("kept message = %q, want %q", first.Content[1].Text, "three")
+    }
+}
+
+func TestCompactConversationRequestTooLarge(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("a"), anthropictest.TextResponse("b"))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"), goanthropic.WithCompactOnRequestTooLarge())
+    chatTurns(t, client, "one", "two")
+    before := client.GetConversation()
+
+    srv.EnqueueError(http.StatusRequestEntityTooLarge, "request_too_large", "too big")
+    err := client.CompactConversation(context.Background(), 2, nil)
+    var tooLarge *goanthropic.RequestTooLargeError
+    if !errors.As(err, &tooLarge) {
+        t.Fatalf("err = %v, want a *RequestTooLargeError", err)
+    }
+    if after := client.GetConversation(); !reflect.DeepEqual(after, before) {
+        t.Errorf("conversation changed from %d to %d messages", len(before), len(after))
+    }
+    if got := len(srv.Requests()); got != 3 {
+        t.Errorf("sent %d requests, want 3 without a retry", got)
+    }
+}
@@ -0,0 +1,140 @@
+package goanthropic
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// WithAutoContinue makes ChatMe and the other ChatMessage based calls continue
+// a reply that was cut off at max_tokens, up to maxContinuations times, and
+// return the stitched answer as a single response. Each continuation is a
+// separate request sent as with ContinueChat; its usage is counted in
+// SessionUsage and summed in the returned response.
+func WithAutoContinue(maxContinuations int) ClientOption {
+    return func(c *AnthropicClient) {
+        if maxContinuations >= 0 {
+            c.autoContinuations = maxContinuations
+        }
+    }
+}
+
+// autoContinue continues a truncated text response while continuations
+// remain, returning a response that holds the stitched assistant turn
+func (c *AnthropicClient) autoContinue(ctx context.Context, response *types.AnthropicResponse, params *types.MessageParams) (*types.AnthropicResponse, error) {
+    combined := *response
+    for i := 0; i < c.autoContinuations && combined.WasTruncated(); i++ {
+        if n := len(combined.Content); n == 0 || combined.Content[n-1].Type != types.ContentTypeText {
+            break
+        }
+        c.logMessage("Response truncated at max_tokens, continuing (%d of %d)", i+1, c.autoContinuations)
+        next, err := c.ContinueChat(ctx, params)
+        if err != nil {
+            return nil, fmt.Errorf("error continuing truncated response: %w", err)
+        }
+
+        combined.StopReason = next.StopReason
+        combined.Usage.InputTokens += next.Usage.InputTokens
+        combined.Usage.OutputTokens += next.Usage.OutputTokens
+        combined.Usage.CacheCreationInputTokens += next.Usage.CacheCreationInputTokens
+        combined.Usage.CacheReadInputTokens += next.Usage.CacheReadInputTokens
+
+        // Stitch the response blocks rather than copying the stored turn,
+        // which also holds the prefill
+        content := append([]types.MessageContent(nil), combined.Content...)
+        block := &content[len(content)-1]
+        block.Text = strings.TrimRight(block.Text, " \t\r\n") + next.Text()
+        combined.Content = content
+    }
+    return &combined, nil
+}
+
+// ContinueChat resumes an assistant answer that was cut off at max_tokens.
+// The stored conversation must end with the truncated assistant turn; it is
+// sent back as a prefill so the model carries on where it stopped, and the new
+// text is appended to that turn rather than stored as a separate message.
+// Call it again while the returned response reports WasTruncated to stitch
+// together documents longer than a single response.
+func (c *AnthropicClient) ContinueChat(ctx context.Context, params *types.MessageParams) (*types.AnthropicResponse, error) {
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    finalParams := c.mergeParams(params)
+
+    messages := c.conversationSnapshot()
+    last := len(messages) - 1
+    if last < 0 || messages[last].Role != types.RoleAssistant {
+        return nil, fmt.Errorf("conversation does not end with an assistant turn to continue")
+    }
+    prefill := messages[last]
+    if hasContentType(prefill.Content, types.ContentTypeToolUse) {
+        return nil, fmt.Errorf("cannot continue an assistant turn that called a tool")
+    }
+    block := len(prefill.Content) - 1
+    if block < 0 || prefill.Content[block].Type != types.ContentTypeText {
+        return nil, fmt.Errorf("last assistant turn does not end with text")
+    }
+
+    // The API rejects a prefill that ends in whitespace
+    content := append([]types.MessageContent(nil), prefill.Content...)
+    content[block].Text = strings.TrimRight(content[block].Text, " \t\r\n")
+
+    // Compaction keeps the most recent messages, so a rebuilt request still
+    // ends with the turn being continued
+    response, err := c.sendConversation(ctx, func() types.Request {
+        messages := c.conversationSnapshot()
+        if n := len(messages); n > 0 {
+            messages[n-1].Content = content
+        }
+        return types.Request{
+            Model:         finalParams.Model,
+            System:        finalParams.System,
+            SystemBlocks:  finalParams.SystemBlocks,
+            Messages:      messages,
+            MaxTokens:     finalParams.MaxTokens,
+            Temperature:   finalParams.Temperature,
+            TopP:          finalParams.TopP,
+            TopK:          finalParams.TopK,
+            StopSequences: finalParams.StopSequences,
+            Metadata:      finalParams.Metadata,
+        }
+    })
+    if err != nil {
+        return nil, err
+    }
+    c.appendContinuation(prefill, content[block].Text+response.Text())
+    return response, nil
+}
+
+// appendContinuation replaces the text of the final block of the last stored
+// message, provided it is still the assistant turn that was continued
+func (c *AnthropicClient) appendContinuation(continued types.Message, text string) {
+    c.mu.Lock()
+    defer c.unlock()
+
+    last := len(c.conversation) - 1
+    if last < 0 || !sameTurn(c.conversation[last], continued) {
+        c.warn("Conversation changed while continuing a response; continuation not stored")
+        return
+    }
+    msg := &c.conversation[last]
+    msg.Content = append([]types.MessageContent(nil), msg.Content...)
+    msg.Content[len(msg.Content)-1].Text = text
+    c.notifyMessageChange(types.ConversationEventEdit, *msg)
+}
+
+// sameTurn reports whether msg is still the assistant turn continued was
+// copied from. Its ID, which is empty without WithMessageIDs, its number of
+// blocks and its final text must all be unchanged.
+func sameTurn(msg, continued types.Message) bool {
+    if msg.Role != types.RoleAssistant || msg.ID != continued.ID {
+        return false
+    }
+    n := len(msg.Content)
+    if n == 0 || n != len(continued.Content) {
+        return false
+    }
+    return msg.Content[n-1].Text == continued.Content[n-1].Text
+}
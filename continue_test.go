@@ -0,0 +1,74 @@
+package goanthropic_test
+
+import (
+    "context"
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// truncatedResponse returns a text reply cut off at max_tokens
+func truncatedResponse(text string) types.AnthropicResponse {
+    response := anthropictest.TextResponse(text)
+    response.StopReason = types.StopReasonMaxTokens
+    return response
+}
+
+func TestAutoContinueOmitsPrefill(t *testing.T) {
+    srv := anthropictest.NewServer(truncatedResponse(`"a": 1, `), anthropictest.TextResponse(`"b": 2}`))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"), goanthropic.WithAutoContinue(2))
+
+    content := []types.MessageContent{{Type: types.ContentTypeText, Text: "Reply in JSON"}}
+    response, err := client.ChatMessage(context.Background(), content, &types.MessageParams{Prefill: "{"})
+    if err != nil {
+        t.Fatalf("ChatMessage: %v", err)
+    }
+    if got := response.Text(); got != `"a": 1,"b": 2}` {
+        t.Errorf("stitched response = %q, want it without the prefill", got)
+    }
+    if response.StopReason != types.StopReasonEndTurn {
+        t.Errorf("stop reason = %s", response.StopReason)
+    }
+    if got := lastText(client.GetConversation()); got != `{"a": 1,"b": 2}` {
+        t.Errorf("stored reply = %q, want the prefill joined to both parts", got)
+    }
+}
+
+func TestContinueChatSkipsChangedTurn(t *testing.T) {
+    srv := anthropictest.NewServer(
+        truncatedResponse("Once upon"),
+        anthropictest.TextResponse("Other answer"),
+        anthropictest.TextResponse(" a time"),
+    )
+    defer srv.Close()
+    warnings := &warningRecorder{}
+    var client *goanthropic.AnthropicClient
+    requests := 0
+    client = srv.Client(
+        goanthropic.WithModel("claude-3-5-sonnet-20241022"),
+        goanthropic.WithWarningHandler(warnings.handle),
+        goanthropic.WithRequestSigner(func(body []byte, headers http.Header) {
+            // Another call replaces the last assistant turn while the
+            // continuation is in flight
+            if requests++; requests == 2 {
+                chatTurns(t, client, "Another question")
+            }
+        }),
+    )
+
+    chatTurns(t, client, "Tell a story")
+    if _, err := client.ContinueChat(context.Background(), nil); err != nil {
+        t.Fatalf("ContinueChat: %v", err)
+    }
+    if got := lastText(client.GetConversation()); got != "Other answer" {
+        t.Errorf("last turn = %q, want the other call's answer left alone", got)
+    }
+    if all := warnings.all(); len(all) != 1 || !strings.Contains(all[0], "continuation not stored") {
+        t.Errorf("warnings = %q", all)
+    }
+}
@@ -0,0 +1,322 @@
+package goanthropic
+
+import (
+    "crypto/rand"
+    "fmt"
+    "time"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// WithConversationMaxAge drops stored messages older than maxAge before each
+// request. Eviction never separates a tool_use from its tool_result, so a few
+// messages past the limit may be kept until a safe boundary is reached.
+func WithConversationMaxAge(maxAge time.Duration) ClientOption {
+    return func(c *AnthropicClient) {
+        if maxAge > 0 {
+            c.maxConvAge = maxAge
+        }
+    }
+}
+
+// WithConversationObserver registers a callback that is told about every
+// change to the stored conversation: appends, trims, edits, deletes and
+// clears. Observers run synchronously on the goroutine that made the change,
+// after the client's lock is released, so they may call back into the client.
+func WithConversationObserver(observer func(types.ConversationEvent)) ClientOption {
+    return func(c *AnthropicClient) {
+        c.conversationObserver = observer
+    }
+}
+
+// notifyConversation queues a change for the conversation observer, if any.
+// The caller must hold c.mu; the event is delivered by unlock.
+func (c *AnthropicClient) notifyConversation(event types.ConversationEvent) {
+    if c.conversationObserver == nil {
+        return
+    }
+    event.Length = len(c.conversation)
+    c.pendingEvents = append(c.pendingEvents, event)
+}
+
+// unlock releases c.mu and then delivers the conversation events queued while it was held
+func (c *AnthropicClient) unlock() {
+    events := c.pendingEvents
+    c.pendingEvents = nil
+    c.mu.Unlock()
+
+    for _, event := range events {
+        c.conversationObserver(event)
+    }
+}
+
+// GetConversation returns a copy of the stored conversation. Changing the
+// returned messages does not affect the client.
+func (c *AnthropicClient) GetConversation() []types.Message {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    messages := make([]types.Message, len(c.conversation))
+    for i, msg := range c.conversation {
+        messages[i] = msg
+        messages[i].Content = append([]types.MessageContent(nil), msg.Content...)
+    }
+    return messages
+}
+
+// ResetConversation clears the stored conversation for a fresh start. The
+// system prompt, default params and other configuration are kept.
+func (c *AnthropicClient) ResetConversation() {
+    c.mu.Lock()
+    defer c.unlock()
+
+    removed := len(c.conversation)
+    c.conversation = nil
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventClear, Removed: removed})
+}
+
+// conversationSnapshot returns a copy of the conversation for building a
+// request, so the request is unaffected by later changes
+func (c *AnthropicClient) conversationSnapshot() []types.Message {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return append([]types.Message(nil), c.conversation...)
+}
+
+// notifyMessageChange reports an appended or edited message with a copy of its content
+func (c *AnthropicClient) notifyMessageChange(eventType string, msg types.Message) {
+    if c.conversationObserver == nil {
+        return
+    }
+    msg.Content = append([]types.MessageContent(nil), msg.Content...)
+    c.notifyConversation(types.ConversationEvent{Type: eventType, Message: &msg})
+}
+
+// evictExpiredMessages removes messages older than the configured maximum age.
+// The caller must hold c.mu.
+func (c *AnthropicClient) evictExpiredMessages() {
+    if c.maxConvAge <= 0 {
+        return
+    }
+
+    cutoff := c.now().Add(-c.maxConvAge)
+    expired := 0
+    for expired < len(c.conversation) {
+        created := c.conversation[expired].CreatedAt
+        if created.IsZero() || !created.Before(cutoff) {
+            break
+        }
+        expired++
+    }
+    if expired == 0 {
+        return
+    }
+
+    start := safeStartIndex(c.conversation, expired)
+    c.logMessage("Evicting %d messages older than %s", start, c.maxConvAge)
+    c.conversation = c.conversation[start:]
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventTrim, Removed: start})
+}
+
+// safeStartIndex returns the first index at or after start where the
+// conversation can begin: a user message that does not answer an earlier
+// tool_use. Starting anywhere else would orphan tool_result blocks.
+func safeStartIndex(messages []types.Message, start int) int {
+    for start < len(messages) {
+        msg := messages[start]
+        if msg.Role == types.RoleUser && !hasContentType(msg.Content, types.ContentTypeToolResult) {
+            return start
+        }
+        start++
+    }
+    return start
+}
+
+// hasContentType reports whether any block in content has the given type
+func hasContentType(content []types.MessageContent, contentType string) bool {
+    for _, block := range content {
+        if block.Type == contentType {
+            return true
+        }
+    }
+    return false
+}
+
+// WithMessageIDs assigns every stored message a random UUID. IDs stay attached
+// to their message when the conversation is trimmed, so they can be used to
+// look up, edit or delete messages where indexes would shift.
+func WithMessageIDs() ClientOption {
+    return func(c *AnthropicClient) {
+        c.messageIDs = true
+    }
+}
+
+// GetMessageByID returns the stored message with the given ID
+func (c *AnthropicClient) GetMessageByID(id string) (types.Message, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    index := c.messageIndex(id)
+    if index < 0 {
+        return types.Message{}, false
+    }
+    msg := c.conversation[index]
+    msg.Content = append([]types.MessageContent(nil), msg.Content...)
+    return msg, true
+}
+
+// EditMessageByID replaces the content of the stored message with the given ID
+func (c *AnthropicClient) EditMessageByID(id string, content []types.MessageContent) error {
+    c.mu.Lock()
+    defer c.unlock()
+
+    index := c.messageIndex(id)
+    if index < 0 {
+        return fmt.Errorf("message %s not found", id)
+    }
+    if len(content) == 0 {
+        return fmt.Errorf("message content cannot be empty")
+    }
+    c.conversation[index].Content = append([]types.MessageContent(nil), content...)
+    c.notifyMessageChange(types.ConversationEventEdit, c.conversation[index])
+    return nil
+}
+
+// DeleteMessageByID removes the stored message with the given ID. Deleting one
+// half of a tool_use/tool_result pair removes the other half as well, so the
+// history never contains orphaned tool blocks. Callers are responsible for
+// keeping user and assistant turns alternating.
+func (c *AnthropicClient) DeleteMessageByID(id string) error {
+    c.mu.Lock()
+    defer c.unlock()
+
+    index := c.messageIndex(id)
+    if index < 0 {
+        return fmt.Errorf("message %s not found", id)
+    }
+
+    start, end := index, index+1
+    msg := c.conversation[index]
+    if msg.Role == types.RoleAssistant && hasContentType(msg.Content, types.ContentTypeToolUse) &&
+        end < len(c.conversation) && hasContentType(c.conversation[end].Content, types.ContentTypeToolResult) {
+        end++
+    }
+    if msg.Role == types.RoleUser && hasContentType(msg.Content, types.ContentTypeToolResult) &&
+        start > 0 && hasContentType(c.conversation[start-1].Content, types.ContentTypeToolUse) {
+        start--
+    }
+
+    c.conversation = append(c.conversation[:start:start], c.conversation[end:]...)
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventDelete, Removed: end - start})
+    return nil
+}
+
+// RemoveLastMessage removes the most recent stored message. Removing a
+// tool_result turn also removes the tool_use turn it answers, so the history
+// never contains orphaned tool blocks.
+func (c *AnthropicClient) RemoveLastMessage() error {
+    c.mu.Lock()
+    defer c.unlock()
+
+    end := len(c.conversation)
+    if end == 0 {
+        return fmt.Errorf("conversation is empty")
+    }
+    start := end - 1
+    if msg := c.conversation[start]; msg.Role == types.RoleUser && hasContentType(msg.Content, types.ContentTypeToolResult) &&
+        start > 0 && hasContentType(c.conversation[start-1].Content, types.ContentTypeToolUse) {
+        start--
+    }
+    c.removeFrom(start)
+    return nil
+}
+
+// RemoveLastExchange removes the most recent user question and everything
+// after it: the assistant reply and any tool calls made while answering. Use
+// it to edit a question and regenerate the answer.
+func (c *AnthropicClient) RemoveLastExchange() error {
+    c.mu.Lock()
+    defer c.unlock()
+
+    for i := len(c.conversation) - 1; i >= 0; i-- {
+        msg := c.conversation[i]
+        if msg.Role == types.RoleUser && !hasContentType(msg.Content, types.ContentTypeToolResult) {
+            c.removeFrom(i)
+            return nil
+        }
+    }
+    if len(c.conversation) == 0 {
+        return fmt.Errorf("conversation is empty")
+    }
+    return fmt.Errorf("conversation has no user message to remove")
+}
+
+// userTurnLength returns the number of blocks in the last message when it is
+// a user turn, which a new user message would be merged into, or zero
+func (c *AnthropicClient) userTurnLength() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    last := len(c.conversation) - 1
+    if last < 0 || c.conversation[last].Role != types.RoleUser {
+        return 0
+    }
+    return len(c.conversation[last].Content)
+}
+
+// removeUnansweredTurn removes a user turn that received no reply. prior is
+// the userTurnLength before the turn was added: blocks that were already in
+// the message are kept, and the message is removed when none were.
+func (c *AnthropicClient) removeUnansweredTurn(prior int) {
+    c.mu.Lock()
+    defer c.unlock()
+
+    last := len(c.conversation) - 1
+    if last < 0 || c.conversation[last].Role != types.RoleUser {
+        return
+    }
+    msg := &c.conversation[last]
+    if prior <= 0 {
+        c.removeFrom(last)
+        return
+    }
+    if prior >= len(msg.Content) {
+        return
+    }
+    msg.Content = append([]types.MessageContent(nil), msg.Content[:prior]...)
+    c.notifyMessageChange(types.ConversationEventEdit, *msg)
+}
+
+// removeFrom drops the message at index and every later message. The caller
+// must hold c.mu.
+func (c *AnthropicClient) removeFrom(index int) {
+    removed := len(c.conversation) - index
+    c.conversation = c.conversation[:index:index]
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventDelete, Removed: removed})
+}
+
+// messageIndex returns the position of the message with the given ID, or -1.
+// The caller must hold c.mu.
+func (c *AnthropicClient) messageIndex(id string) int {
+    if id == "" {
+        return -1
+    }
+    for i, msg := range c.conversation {
+        if msg.ID == id {
+            return i
+        }
+    }
+    return -1
+}
+
+// newMessageID returns a random version 4 UUID
+func newMessageID() string {
+    var b [16]byte
+    if _, err := rand.Read(b[:]); err != nil {
+        // crypto/rand does not fail on supported platforms; fall back to a time-based ID
+        return fmt.Sprintf("msg-%d", time.Now().UnixNano())
+    }
+    b[6] = (b[6] & 0x0f) | 0x40
+    b[8] = (b[8] & 0x3f) | 0x80
+    return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,194 @@
+package goanthropic
+
+import (
+    "testing"
+    "time"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// fakeClock is a settable time source for c.now
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time { return f.t }
+
+// text returns a single text block
+func text(s string) []types.MessageContent {
+    return []types.MessageContent{{Type: types.ContentTypeText, Text: s}}
+}
+
+func TestMergedMessageIsNotEvictedByAge(t *testing.T) {
+    clock := &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+    c := NewClient("test-key", WithConversationMaxAge(time.Hour))
+    c.now = clock.now
+
+    c.addMessageToConversation(types.RoleUser, text("first"))
+    clock.t = clock.t.Add(50 * time.Minute)
+    c.addMessageToConversation(types.RoleUser, text("second"))
+
+    conversation := c.GetConversation()
+    if len(conversation) != 1 || len(conversation[0].Content) != 2 {
+        t.Fatalf("conversation = %+v, want one merged message", conversation)
+    }
+    if !conversation[0].CreatedAt.Equal(clock.t) {
+        t.Errorf("CreatedAt = %v, want the merge time %v", conversation[0].CreatedAt, clock.t)
+    }
+
+    clock.t = clock.t.Add(20 * time.Minute)
+    c.trimConversationHistory(0)
+    if got := len(c.GetConversation()); got != 1 {
+        t.Fatalf("conversation has %d messages after 70 minutes, want the merged message kept", got)
+    }
+
+    clock.t = clock.t.Add(time.Hour)
+    c.trimConversationHistory(0)
+    if got := len(c.GetConversation()); got != 0 {
+        t.Errorf("conversation has %d messages after two hours, want 0", got)
+    }
+}
+
+func TestMessageIDsStableAcrossTrims(t *testing.T) {
+    c := NewClient("test-key", WithMessageIDs())
+    for _, turn := range [][2]string{{"one", "a"}, {"two", "b"}} {
+        c.addMessageToConversation(types.RoleUser, text(turn[0]))
+        c.addMessageToConversation(types.RoleAssistant, text(turn[1]))
+    }
+    before := append([]types.Message(nil), c.conversation...)
+    seen := make(map[string]bool)
+    for _, msg := range before {
+        if msg.ID == "" || seen[msg.ID] {
+            t.Fatalf("message IDs %+v are not unique and non-empty", before)
+        }
+        seen[msg.ID] = true
+    }
+
+    // Trimming drops the first exchange; the rest keep their IDs
+    c.addMessageToConversation(types.RoleUser, text("three"))
+    c.addMessageToConversation(types.RoleAssistant, text("c"))
+    c.trimConversationHistory(4)
+    after := c.conversation
+    if len(after) != 4 {
+        t.Fatalf("conversation has %d messages, want 4", len(after))
+    }
+    if after[0].ID != before[2].ID || after[1].ID != before[3].ID {
+        t.Errorf("IDs changed across the trim: before %q %q, after %q %q", before[2].ID, before[3].ID, after[0].ID, after[1].ID)
+    }
+    if _, ok := c.GetMessageByID(before[0].ID); ok {
+        t.Error("a trimmed message can still be looked up")
+    }
+
+    if err := c.EditMessageByID(before[2].ID, text("TWO")); err != nil {
+        t.Fatalf("EditMessageByID: %v", err)
+    }
+    if msg, ok := c.GetMessageByID(before[2].ID); !ok || msg.Content[0].Text != "TWO" {
+        t.Errorf("edited message = %+v", msg)
+    }
+
+    last := after[3].ID
+    if err := c.DeleteMessageByID(last); err != nil {
+        t.Fatalf("DeleteMessageByID: %v", err)
+    }
+    if _, ok := c.GetMessageByID(last); ok {
+        t.Error("a deleted message can still be looked up")
+    }
+    if err := c.DeleteMessageByID(last); err == nil {
+        t.Error("deleting an unknown ID succeeded")
+    }
+}
+
+// toolUse returns an assistant tool_use block
+func toolUse(id string) []types.MessageContent {
+    return []types.MessageContent{{Type: types.ContentTypeToolUse, ID: id, Name: "search", Input: []byte(`{}`)}}
+}
+
+// toolResult returns a user tool_result block
+func toolResult(id string) []types.MessageContent {
+    return []types.MessageContent{{Type: types.ContentTypeToolResult, ToolUseID: id, Content: "result"}}
+}
+
+func TestTrimNeverOrphansToolResults(t *testing.T) {
+    turns := []struct {
+        role    string
+        content []types.MessageContent
+    }{
+        {types.RoleUser, text("find a and b")},
+        {types.RoleAssistant, toolUse("t1")},
+        {types.RoleUser, toolResult("t1")},
+        {types.RoleAssistant, toolUse("t2")},
+        {types.RoleUser, toolResult("t2")},
+        {types.RoleAssistant, text("found both")},
+        {types.RoleUser, text("now c")},
+        {types.RoleAssistant, toolUse("t3")},
+        {types.RoleUser, toolResult("t3")},
+        {types.RoleAssistant, text("found c")},
+    }
+
+    for limit := 1; limit <= len(turns); limit++ {
+        c := NewClient("test-key")
+        for _, turn := range turns {
+            c.addMessageToConversation(turn.role, turn.content)
+        }
+        c.trimConversationHistory(limit)
+
+        conversation := c.GetConversation()
+        if len(conversation) == 0 {
+            t.Errorf("limit %d: conversation trimmed to nothing", limit)
+            continue
+        }
+        if conversation[0].Role != types.RoleUser {
+            t.Errorf("limit %d: conversation starts with %s", limit, conversation[0].Role)
+        }
+        used := make(map[string]bool)
+        for i, msg := range conversation {
+            for _, block := range msg.Content {
+                switch block.Type {
+                case types.ContentTypeToolUse:
+                    used[block.ID] = true
+                case types.ContentTypeToolResult:
+                    if !used[block.ToolUseID] {
+                        t.Errorf("limit %d: message %d answers %s, whose tool_use was trimmed", limit, i, block.ToolUseID)
+                    }
+                }
+            }
+        }
+    }
+}
+
+func TestSameRoleMessagesMerge(t *testing.T) {
+    tests := []struct {
+        name string
+        role string
+    }{
+        {"user+user", types.RoleUser},
+        {"assistant+assistant", types.RoleAssistant},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            c := NewClient("test-key", WithMessageIDs())
+            if tt.role == types.RoleAssistant {
+                c.addMessageToConversation(types.RoleUser, text("question"))
+            }
+            c.addMessageToConversation(tt.role, text("first"))
+            id := c.GetConversation()[len(c.GetConversation())-1].ID
+            before := c.conversationSnapshot()
+
+            c.addMessageToConversation(tt.role, text("second"))
+
+            conversation := c.GetConversation()
+            last := conversation[len(conversation)-1]
+            if got := len(conversation); got != len(before) {
+                t.Fatalf("conversation has %d messages, want %d", got, len(before))
+            }
+            if last.Role != tt.role || len(last.Content) != 2 || last.Content[0].Text != "first" || last.Content[1].Text != "second" {
+                t.Errorf("merged message = %+v", last)
+            }
+            if last.ID != id {
+                t.Errorf("merge changed the message ID from %q to %q", id, last.ID)
+            }
+            // A snapshot taken before the merge is not changed by it
+            if got := len(before[len(before)-1].Content); got != 1 {
+                t.Errorf("earlier snapshot has %d blocks, want 1", got)
+            }
+        })
+    }
+}
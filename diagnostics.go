@@ -0,0 +1,122 @@
+package goanthropic
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "time"
+)
+
+// maxRecentErrors bounds the number of request errors kept for DumpState
+const maxRecentErrors = 10
+
+// recordedError is a request failure kept for diagnostics
+type recordedError struct {
+    at      time.Time
+    message string
+}
+
+// recordError remembers a failed request, keeping only the most recent ones
+func (c *AnthropicClient) recordError(err error) {
+    c = c.root()
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.recentErrors = append(c.recentErrors, recordedError{at: c.now(), message: err.Error()})
+    if len(c.recentErrors) > maxRecentErrors {
+        c.recentErrors = c.recentErrors[len(c.recentErrors)-maxRecentErrors:]
+    }
+}
+
+// DumpState writes a diagnostic snapshot of the client to w, suitable for
+// attaching to bug reports. It covers configuration, conversation size, the
+// last token usage, tool result statistics and recent errors. The API key is
+// masked and message content is not included. The snapshot is taken under the
+// client's lock, but written to w after the lock is released, so a slow writer
+// does not block other calls.
+func (c *AnthropicClient) DumpState(w io.Writer) error {
+    var state bytes.Buffer
+    c.mu.Lock()
+    c.writeState(&state)
+    c.mu.Unlock()
+
+    _, err := w.Write(state.Bytes())
+    return err
+}
+
+// writeState renders the DumpState snapshot. The caller must hold c.mu.
+func (c *AnthropicClient) writeState(state *bytes.Buffer) {
+    printf := func(format string, args ...interface{}) {
+        fmt.Fprintf(state, format, args...)
+    }
+
+    printf("== Config ==\n")
+    printf("api_key: %s\n", maskAPIKey(c.apiKey))
+    printf("model: %s\n", c.defaultParams.Model)
+    printf("max_tokens: %d\n", c.defaultParams.MaxTokens)
+    printf("tools: %d\n", len(c.defaultParams.Tools))
+    printf("system_prompt_bytes: %d\n", len(c.systemPrompt))
+    printf("max_conversation_length: %d\n", c.maxConvLength)
+    printf("max_conversation_age: %s\n", c.maxConvAge)
+    printf("tool_result_warn_bytes: %d\n", c.toolResultWarnBytes)
+    printf("response_validator: %t\n", c.responseValidator != nil)
+    printf("validator_retries: %d\n", c.validatorRetries)
+    printf("min_response_tokens: %d\n", c.minResponseTokens)
+
+    printf("\n== Conversation ==\n")
+    printf("messages: %d\n", len(c.conversation))
+
+    printf("\n== Last Usage ==\n")
+    printf("input_tokens: %d\n", c.lastUsage.InputTokens)
+    printf("output_tokens: %d\n", c.lastUsage.OutputTokens)
+    printf("cache_creation_input_tokens: %d\n", c.lastUsage.CacheCreationInputTokens)
+    printf("cache_read_input_tokens: %d\n", c.lastUsage.CacheReadInputTokens)
+
+    printf("\n== Total Usage ==\n")
+    printf("input_tokens: %d\n", c.totalUsage.InputTokens)
+    printf("output_tokens: %d\n", c.totalUsage.OutputTokens)
+    printf("cache_creation_input_tokens: %d\n", c.totalUsage.CacheCreationInputTokens)
+    printf("cache_read_input_tokens: %d\n", c.totalUsage.CacheReadInputTokens)
+
+    printf("\n== Tool Stats ==\n")
+    names := make([]string, 0, len(c.toolStats))
+    for name := range c.toolStats {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+    if len(names) == 0 {
+        printf("(none)\n")
+    }
+    for _, name := range names {
+        stat := c.toolStats[name]
+        printf("%s: calls=%d total_bytes=%d max_bytes=%d\n", name, stat.calls, stat.totalBytes, stat.maxBytes)
+    }
+
+    printf("\n== Recent Errors ==\n")
+    if len(c.recentErrors) == 0 {
+        printf("(none)\n")
+    }
+    for _, rec := range c.recentErrors {
+        printf("[%s] %s\n", rec.at.Format(time.RFC3339), maskSecret(rec.message, c.apiKey))
+    }
+}
+
+// maskAPIKey hides all but the last four characters of an API key
+func maskAPIKey(key string) string {
+    if key == "" {
+        return "(not set)"
+    }
+    if len(key) <= 8 {
+        return "****"
+    }
+    return "****" + key[len(key)-4:]
+}
+
+// maskSecret replaces any occurrence of secret in s with its masked form
+func maskSecret(s, secret string) string {
+    if secret == "" {
+        return s
+    }
+    return strings.ReplaceAll(s, secret, maskAPIKey(secret))
+}
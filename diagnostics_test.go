@@ -0,0 +1,54 @@
+package goanthropic_test
+
+import (
+    "bytes"
+    "context"
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+)
+
+const secretKey = "sk-ant-REDACTED"
+
+// reentrantWriter calls back into the client on every write
+type reentrantWriter struct {
+    bytes.Buffer
+    client *goanthropic.AnthropicClient
+}
+
+func (w *reentrantWriter) Write(p []byte) (int, error) {
+    w.client.GetConversation()
+    return w.Buffer.Write(p)
+}
+
+func TestDumpState(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("a"))
+    defer srv.Close()
+    client := goanthropic.NewClient(secretKey, goanthropic.WithBaseURL(srv.URL))
+    chatTurns(t, client, "one")
+    srv.EnqueueError(http.StatusBadRequest, "invalid_request_error", "bad key "+secretKey)
+    if _, err := client.ChatMe(context.Background(), "two", nil); err == nil {
+        t.Fatal("expected the queued error")
+    }
+
+    // A writer that uses the client must not deadlock
+    w := &reentrantWriter{client: client}
+    if err := client.DumpState(w); err != nil {
+        t.Fatalf("DumpState: %v", err)
+    }
+    dump := w.String()
+    for _, section := range []string{"== Config ==", "== Conversation ==", "== Last Usage ==", "== Total Usage ==", "== Tool Stats ==", "== Recent Errors =="} {
+        if !strings.Contains(dump, section) {
+            t.Errorf("dump is missing %q", section)
+        }
+    }
+    if strings.Contains(dump, secretKey) {
+        t.Error("dump contains the API key")
+    }
+    if !strings.Contains(dump, "****cdef") || !strings.Contains(dump, "bad key") {
+        t.Errorf("dump does not show the masked key and recent error:\n%s", dump)
+    }
+}
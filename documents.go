@@ -0,0 +1,54 @@
+package goanthropic
+
+import (
+    "bytes"
+    "context"
+    "encoding/base64"
+    "fmt"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// pdfBeta enables PDF document blocks on API versions that still gate them
+const pdfBeta = "pdfs-2024-09-25"
+
+// pdfMagic is the signature every PDF file starts with
+var pdfMagic = []byte("%PDF")
+
+// ChatWithDocument sends text together with a PDF in a single user message so
+// the model can answer questions about it. The PDF is base64-encoded and
+// placed before the text. Data that does not start with the %PDF signature is
+// rejected.
+func (c *AnthropicClient) ChatWithDocument(ctx context.Context, text string, pdf []byte, params *types.MessageParams) (*types.AnthropicResponse, error) {
+    if !bytes.HasPrefix(pdf, pdfMagic) {
+        return nil, fmt.Errorf("document is not a PDF")
+    }
+
+    content := []types.MessageContent{{
+        Type: types.ContentTypeDocument,
+        Source: &types.ImageSource{
+            Type:      types.ImageSourceBase64,
+            MediaType: types.MediaTypePDF,
+            Data:      base64.StdEncoding.EncodeToString(pdf),
+        },
+    }}
+    if text != "" {
+        content = append(content, types.MessageContent{
+            Type: types.ContentTypeText,
+            Text: text,
+        })
+    }
+    return c.ChatMessage(ctx, content, params)
+}
+
+// hasDocuments reports whether any message carries a document block
+func hasDocuments(messages []types.Message) bool {
+    for _, msg := range messages {
+        for _, block := range msg.Content {
+            if block.Type == types.ContentTypeDocument {
+                return true
+            }
+        }
+    }
+    return false
+}
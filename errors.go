@@ -0,0 +1,210 @@
+package goanthropic
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// malformedRetryDelay is the default pause before re-requesting a malformed
+// response; see WithBackoff
+const malformedRetryDelay = 500 * time.Millisecond
+
+// API error types reported in the body of failed responses
+const (
+    errorTypeAuthentication = "authentication_error"
+    errorTypePermission     = "permission_error"
+    errorTypeRateLimit      = "rate_limit_error"
+    errorTypeOverloaded     = "overloaded_error"
+    errorTypeTooLarge       = "request_too_large"
+)
+
+// statusOverloaded is the non-standard status the API uses when it is overloaded
+const statusOverloaded = 529
+
+// APIError is returned for every non-200 response from the API, and for error
+// events received while streaming (which have no StatusCode). Use errors.As
+// to inspect it, or the IsRateLimited, IsAuthError and IsOverloaded helpers.
+type APIError struct {
+    StatusCode int
+    Type       string
+    Message    string
+}
+
+func (e *APIError) Error() string {
+    if e.Type == "" {
+        return fmt.Sprintf("error response status %d: %s", e.StatusCode, e.Message)
+    }
+    return fmt.Sprintf("API error: %s - %s", e.Type, e.Message)
+}
+
+// IsRateLimited reports whether err is an API rate limit error
+func IsRateLimited(err error) bool {
+    var apiErr *APIError
+    return errors.As(err, &apiErr) &&
+        (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.Type == errorTypeRateLimit)
+}
+
+// IsAuthError reports whether err is an API authentication or permission error
+func IsAuthError(err error) bool {
+    var apiErr *APIError
+    return errors.As(err, &apiErr) &&
+        (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden ||
+            apiErr.Type == errorTypeAuthentication || apiErr.Type == errorTypePermission)
+}
+
+// IsOverloaded reports whether err is an API overloaded error
+func IsOverloaded(err error) bool {
+    var apiErr *APIError
+    return errors.As(err, &apiErr) &&
+        (apiErr.StatusCode == statusOverloaded || apiErr.Type == errorTypeOverloaded)
+}
+
+// ErrEmptyResponse is matched by errors.Is when the model replied without any
+// content that could be stored in the conversation
+var ErrEmptyResponse = errors.New("response has no content")
+
+// defaultMaxToolIterations is the number of model turns ChatWithTools allows by default
+const defaultMaxToolIterations = 10
+
+// ErrMaxIterations is matched by errors.Is when ChatWithTools stops because it
+// reached its tool iteration limit
+var ErrMaxIterations = errors.New("exceeded maximum number of tool call iterations")
+
+// MaxIterationsError is returned when ChatWithTools reaches its tool iteration
+// limit. Conversation holds a copy of the history at that point; the client
+// keeps it too, so calling ChatWithTools again continues from there.
+type MaxIterationsError struct {
+    Limit        int
+    Conversation []types.Message
+}
+
+func (e *MaxIterationsError) Error() string {
+    return fmt.Sprintf("%v (%d)", ErrMaxIterations, e.Limit)
+}
+
+// Unwrap returns ErrMaxIterations
+func (e *MaxIterationsError) Unwrap() error {
+    return ErrMaxIterations
+}
+
+// WithMaxToolIterations sets how many model turns a single ChatWithTools call
+// may take before returning a *MaxIterationsError. The default is 10.
+func WithMaxToolIterations(n int) ClientOption {
+    return func(c *AnthropicClient) {
+        if n > 0 {
+            c.maxToolIterations = n
+        }
+    }
+}
+
+// RequestTooLargeError is returned when the API or a proxy rejects a request
+// with HTTP 413 because the body is too large
+type RequestTooLargeError struct {
+    StatusCode int
+    Message    string
+}
+
+func (e *RequestTooLargeError) Error() string {
+    msg := fmt.Sprintf("request too large (status %d)", e.StatusCode)
+    if e.Message != "" {
+        msg += ": " + e.Message
+    }
+    return msg + "; shorten the conversation with WithMaxConversationLength, reduce tool result sizes, " +
+        "or enable WithCompactOnRequestTooLarge"
+}
+
+// Unwrap exposes the failure as an *APIError so all API failures can be handled alike
+func (e *RequestTooLargeError) Unwrap() error {
+    return &APIError{StatusCode: e.StatusCode, Type: errorTypeTooLarge, Message: e.Message}
+}
+
+// WithCompactOnRequestTooLarge drops the oldest half of the conversation and
+// retries once when a request is rejected as too large
+func WithCompactOnRequestTooLarge() ClientOption {
+    return func(c *AnthropicClient) {
+        c.compactOnTooLarge = true
+    }
+}
+
+// WithMalformedResponseRetries re-sends a request up to retries times when the
+// API answers 200 with a body that is not valid JSON, as happens when a proxy
+// truncates the response. It is off by default so that genuine parsing bugs
+// are not masked.
+func WithMalformedResponseRetries(retries int) ClientOption {
+    return func(c *AnthropicClient) {
+        if retries >= 0 {
+            c.malformedRetries = retries
+        }
+    }
+}
+
+// WithRetries re-sends a request up to retries times when the API answers
+// with a rate limit (429), overload (529) or other server (5xx) error, waiting
+// between attempts as set by WithBackoff. It is off by default, so these
+// errors are returned to the caller straight away.
+func WithRetries(retries int) ClientOption {
+    return func(c *AnthropicClient) {
+        if retries >= 0 {
+            c.retries = retries
+        }
+    }
+}
+
+// isRetryableStatus reports whether a request that failed with statusCode
+// may succeed when sent again
+func isRetryableStatus(statusCode int) bool {
+    return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// sendConversation sends the request that build makes from the stored
+// conversation. With WithCompactOnRequestTooLarge, a request rejected as too
+// large is built again after compacting the conversation and resent once.
+// Requests that are not built from the conversation go through sendRequest,
+// which never compacts.
+func (c *AnthropicClient) sendConversation(ctx context.Context, build func() types.Request) (*types.AnthropicResponse, error) {
+    response, err := c.sendRequest(ctx, build())
+    var tooLarge *RequestTooLargeError
+    if !errors.As(err, &tooLarge) || !c.compactOnTooLarge || !c.compactForRetry() {
+        return response, err
+    }
+    reqBody := build()
+    c.logMessage("Request too large, retrying with %d messages", len(reqBody.Messages))
+    return c.sendRequest(ctx, reqBody)
+}
+
+// compactForRetry drops roughly the oldest half of the conversation at a safe
+// boundary. It reports false when nothing could be removed.
+func (c *AnthropicClient) compactForRetry() bool {
+    c.mu.Lock()
+    defer c.unlock()
+
+    n := len(c.conversation)
+    start := safeStartIndex(c.conversation, n/2)
+    if start == 0 || start >= n {
+        return false
+    }
+    c.conversation = c.conversation[start:]
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventTrim, Removed: start})
+    return true
+}
+
+// errorMessage extracts the message from an API error body, falling back to
+// the raw body for responses that are not JSON, such as proxy error pages
+func errorMessage(body []byte) string {
+    var errorResp struct {
+        Error struct {
+            Message string `json:"message"`
+        } `json:"error"`
+    }
+    if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Error.Message != "" {
+        return errorResp.Error.Message
+    }
+    return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,117 @@
+package goanthropic_test
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// chatTurns sends each message with ChatMe, failing the test on an error
+func chatTurns(t *testing.T, client *goanthropic.AnthropicClient, messages ...string) {
+    t.Helper()
+    for _, message := range messages {
+        if _, err := client.ChatMe(context.Background(), message, nil); err != nil {
+            t.Fatalf("ChatMe(%q): %v", message, err)
+        }
+    }
+}
+
+func TestCompactOnRequestTooLargeRebuildsRequest(t *testing.T) {
+    srv := anthropictest.NewServer(
+        anthropictest.TextResponse("a"),
+        anthropictest.TextResponse("b"),
+        anthropictest.TextResponse("c"),
+    )
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithCompactOnRequestTooLarge())
+    chatTurns(t, client, "one", "two", "three")
+
+    srv.EnqueueError(http.StatusRequestEntityTooLarge, "request_too_large", "too big")
+    srv.Enqueue(anthropictest.TextResponse("d"))
+    resp, err := client.ChatMe(context.Background(), "four", &types.MessageParams{System: "Be brief"})
+    if err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if resp.Text() != "d" {
+        t.Errorf("reply = %q, want %q", resp.Text(), "d")
+    }
+
+    reqs := srv.Requests()
+    rejected, retried := reqs[len(reqs)-2], reqs[len(reqs)-1]
+    if len(retried.Messages) >= len(rejected.Messages) {
+        t.Fatalf("retry sent %d messages, want fewer than %d", len(retried.Messages), len(rejected.Messages))
+    }
+    if retried.Messages[0].Role != types.RoleUser {
+        t.Errorf("retry starts with a %s turn", retried.Messages[0].Role)
+    }
+    if last := retried.Messages[len(retried.Messages)-1]; last.Content[0].Text != "four" {
+        t.Errorf("retry ends with %q, want the new message", last.Content[0].Text)
+    }
+    if retried.System != "Be brief" {
+        t.Errorf("retry system prompt = %q, want %q", retried.System, "Be brief")
+    }
+}
+
+func TestRequestTooLargeWithoutCompaction(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("a"))
+    defer srv.Close()
+    client := srv.Client()
+    chatTurns(t, client, "one")
+
+    srv.EnqueueError(http.StatusRequestEntityTooLarge, "request_too_large", "too big")
+    _, err := client.ChatMe(context.Background(), "two", nil)
+    var tooLarge *goanthropic.RequestTooLargeError
+    if !errors.As(err, &tooLarge) {
+        t.Fatalf("err = %v, want a *RequestTooLargeError", err)
+    }
+    if got := len(srv.Requests()); got != 2 {
+        t.Errorf("sent %d requests, want 2 without a retry", got)
+    }
+}
+
+func TestMalformedResponseRetry(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.EnqueueRaw(http.StatusOK, `{"id":"msg_1","type":"message","content":[{"ty`)
+    srv.Enqueue(anthropictest.TextResponse("Hello"))
+    client := srv.Client(
+        goanthropic.WithModel("claude-3-5-sonnet-20241022"),
+        goanthropic.WithMalformedResponseRetries(1),
+        goanthropic.WithBackoff(goanthropic.BackoffStrategy{Base: time.Millisecond}),
+    )
+
+    resp, err := client.ChatMe(context.Background(), "Hi", nil)
+    if err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if resp.Text() != "Hello" {
+        t.Errorf("reply = %q", resp.Text())
+    }
+    if got := len(srv.Requests()); got != 2 {
+        t.Errorf("sent %d requests, want 2", got)
+    }
+    if conversation := client.GetConversation(); len(conversation) != 2 {
+        t.Errorf("conversation has %d messages, want one exchange", len(conversation))
+    }
+}
+
+func TestMalformedResponseNotRetriedByDefault(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.EnqueueRaw(http.StatusOK, `{"id":"msg_1","type":"message","content":[{"ty`)
+    srv.Enqueue(anthropictest.TextResponse("Hello"))
+    client := srv.Client()
+
+    if _, err := client.ChatMe(context.Background(), "Hi", nil); err == nil {
+        t.Fatal("a malformed response was accepted")
+    }
+    if got := len(srv.Requests()); got != 1 {
+        t.Errorf("sent %d requests, want 1", got)
+    }
+}
@@ -0,0 +1,153 @@
+package goanthropic
+
+import (
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// conversationExportVersion identifies the layout written by ExportConversation
+const conversationExportVersion = 1
+
+// conversationExport is the persisted form of a conversation
+type conversationExport struct {
+    Version      int               `json:"version"`
+    SystemPrompt string            `json:"system_prompt,omitempty"`
+    Messages     []exportedMessage `json:"messages"`
+}
+
+// exportedMessage keeps the client-assigned fields that types.Message does not serialize
+type exportedMessage struct {
+    ID        string                 `json:"id,omitempty"`
+    CreatedAt time.Time              `json:"created_at"`
+    Role      string                 `json:"role"`
+    Content   []types.MessageContent `json:"content"`
+}
+
+// ExportConversation serializes the stored conversation and system prompt to
+// JSON so a session can be saved and later restored with ImportConversation.
+// Message IDs and timestamps are preserved.
+func (c *AnthropicClient) ExportConversation() ([]byte, error) {
+    c.mu.Lock()
+    export := conversationExport{
+        Version:      conversationExportVersion,
+        SystemPrompt: c.systemPrompt,
+        Messages:     make([]exportedMessage, len(c.conversation)),
+    }
+    for i, msg := range c.conversation {
+        export.Messages[i] = exportedMessage{
+            ID:        msg.ID,
+            CreatedAt: msg.CreatedAt,
+            Role:      msg.Role,
+            Content:   msg.Content,
+        }
+    }
+    c.mu.Unlock()
+
+    data, err := json.Marshal(export)
+    if err != nil {
+        return nil, fmt.Errorf("error marshaling conversation: %w", err)
+    }
+    return data, nil
+}
+
+// ImportConversation replaces the stored conversation and system prompt with
+// data written by ExportConversation. The history is validated first: it must
+// start with a user message, use only known roles and content types, and
+// every tool_result must answer a tool_use from the preceding assistant turn.
+// The current conversation is left unchanged when validation fails.
+func (c *AnthropicClient) ImportConversation(data []byte) error {
+    var export conversationExport
+    if err := json.Unmarshal(data, &export); err != nil {
+        return fmt.Errorf("error parsing conversation: %w", err)
+    }
+    if export.Version != conversationExportVersion {
+        return fmt.Errorf("unsupported conversation version %d", export.Version)
+    }
+
+    messages := make([]types.Message, len(export.Messages))
+    for i, msg := range export.Messages {
+        messages[i] = types.Message{
+            Role:      msg.Role,
+            Content:   msg.Content,
+            ID:        msg.ID,
+            CreatedAt: msg.CreatedAt,
+        }
+    }
+    if err := validateConversation(messages); err != nil {
+        return fmt.Errorf("invalid conversation: %w", err)
+    }
+
+    c.mu.Lock()
+    defer c.unlock()
+
+    removed := len(c.conversation)
+    c.conversation = nil
+    c.systemPrompt = export.SystemPrompt
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventClear, Removed: removed})
+    for _, msg := range messages {
+        if msg.ID == "" && c.messageIDs {
+            msg.ID = newMessageID()
+        }
+        c.conversation = append(c.conversation, msg)
+        c.notifyMessageChange(types.ConversationEventAppend, msg)
+    }
+    return nil
+}
+
+// validateConversation checks that messages form a history the API accepts
+func validateConversation(messages []types.Message) error {
+    if len(messages) > 0 && messages[0].Role != types.RoleUser {
+        return fmt.Errorf("first message must be from the user, got %q", messages[0].Role)
+    }
+
+    var pending map[string]bool
+    for i, msg := range messages {
+        if msg.Role != types.RoleUser && msg.Role != types.RoleAssistant {
+            return fmt.Errorf("message %d: unsupported role %q", i, msg.Role)
+        }
+        if len(msg.Content) == 0 {
+            return fmt.Errorf("message %d: content cannot be empty", i)
+        }
+
+        answered := make(map[string]bool)
+        toolUses := make(map[string]bool)
+        for j, block := range msg.Content {
+            switch block.Type {
+            case types.ContentTypeText, types.ContentTypeImage, types.ContentTypeDocument:
+            case types.ContentTypeThinking, types.ContentTypeRedactedThinking:
+                if msg.Role != types.RoleAssistant {
+                    return fmt.Errorf("message %d block %d: %s blocks must come from the assistant", i, j, block.Type)
+                }
+            case types.ContentTypeToolUse:
+                if msg.Role != types.RoleAssistant {
+                    return fmt.Errorf("message %d block %d: tool_use blocks must come from the assistant", i, j)
+                }
+                if block.ID == "" || block.Name == "" {
+                    return fmt.Errorf("message %d block %d: tool_use requires an id and name", i, j)
+                }
+                toolUses[block.ID] = true
+            case types.ContentTypeToolResult:
+                if msg.Role != types.RoleUser {
+                    return fmt.Errorf("message %d block %d: tool_result blocks must come from the user", i, j)
+                }
+                if !pending[block.ToolUseID] {
+                    return fmt.Errorf("message %d block %d: tool_result %q does not answer a preceding tool_use", i, j, block.ToolUseID)
+                }
+                answered[block.ToolUseID] = true
+            default:
+                return fmt.Errorf("message %d block %d: unsupported content type %q", i, j, block.Type)
+            }
+        }
+
+        for id := range pending {
+            if !answered[id] {
+                return fmt.Errorf("message %d: missing tool_result for tool_use %q", i, id)
+            }
+        }
+        pending = toolUses
+    }
+    return nil
+}
@@ -7,40 +7,168 @@ import (
     "fmt"
     "io/ioutil"
     "net/http"
+    "strings"
+    "sync"
+    "time"
     "github.com/rdhillbb/goanthropic/types"
     "github.com/rdhillbb/logging"
 )
 
+// Version is the version of this library, sent in the default User-Agent
+const Version = "0.1.0"
+
 const (
-    defaultAPIEndpoint = "https://api.anthropic.com/v1/messages"
+    defaultUserAgent  = "goanthropic/" + Version
+    defaultBaseURL    = "https://api.anthropic.com"
     defaultModel      = "claude-3-5-sonnet-20241022"
+    defaultAPIVersion = "2023-06-01"
+
+    messagesPath    = "/v1/messages"
+    countTokensPath = "/v1/messages/count_tokens"
+    batchesPath     = "/v1/messages/batches"
+    modelsPath      = "/v1/models"
 )
 
 type ClientOption func(*AnthropicClient)
 
-// AnthropicClient handles all communication with the Anthropic API
+// AnthropicClient handles all communication with the Anthropic API.
+//
+// A client is safe for concurrent use by multiple goroutines. Concurrent calls
+// share one conversation history, so their messages interleave; use a client
+// per conversation when turns must stay in order.
 type AnthropicClient struct {
+    // mu guards the conversation, system prompt, default params, cached
+    // model list, last raw response and all recorded statistics. It is shared
+    // with the detached clients used for stateless calls.
+    mu            *sync.Mutex
+    pendingEvents []types.ConversationEvent
+    parent        *AnthropicClient
+    stateless     bool
+
     apiKey          string
     defaultParams   types.MessageParams
     httpClient      *http.Client
     conversation    []types.Message
     maxConvLength   int
+    maxConvTokens   int
     systemPrompt    string
+
+    warningHandler      func(string)
+    toolResultWarnBytes int
+    toolResultMetrics   []types.ToolResultMetric
+    lastInteractions    []types.ToolInteraction
+    lastToolTrace       []types.ToolEvent
+    toolResultFormat    string
+
+    responseValidator func(*types.AnthropicResponse) error
+    validatorRetries  int
+    minResponseTokens int
+    whitespaceMode    WhitespaceMode
+
+    sortTools      bool
+    strictSampling bool
+
+    toolParallelism   int
+    maxToolIterations int
+    toolObserver      func(types.ToolEvent)
+    schemaValidation  bool
+    toolLoopLimit     int
+    toolLoopMode      ToolLoopMode
+
+    forceFinalAnswer bool
+    finalAnswerLead  int
+
+    maxConvAge time.Duration
+    now        func() time.Time
+    messageIDs bool
+
+    requestSigner func(body []byte, headers http.Header)
+
+    tokenCountConcurrency int
+
+    cacheObservations map[string]*blockObservation
+
+    compactOnTooLarge bool
+    malformedRetries  int
+    retries           int
+    autoContinuations int
+    backoff           BackoffStrategy
+    captureResponses  bool
+
+    lastUsage    types.Usage
+    lastResponse *RawResponse
+    recentErrors []recordedError
+    statsWindow  int
+    turnUsage    []types.Usage
+    totalUsage   types.Usage
+    toolStats    map[string]*toolStat
+    maxImages    int
+    imageLimit   ImageLimitMode
+
+    systemCache *types.CacheControl
+    toolCache   map[string]*types.CacheControl
+
+    pricing map[string]ModelPricing
+
+    cacheModels    bool
+    models         []types.Model
+    validateModels bool
+    knownModels    []string
+
+    customHTTPClient bool
+    forceHTTP1       bool
+    transport        *http.Transport
+    baseURL          string
+    configErr        error
+    middleware       []func(http.RoundTripper) http.RoundTripper
+    betaFeatures     []string
+    longContext      bool
+    apiVersion       string
+    userAgent        string
+
+    logger       Logger
+    redactFields map[string]bool
+
+    defaultCtxTimeout time.Duration
+    requestTimeout    time.Duration
+    httpTimeout       time.Duration
+
+    promptSink func(types.PromptRecord)
+
+    conversationObserver func(types.ConversationEvent)
 }
 
 // NewClient creates a new AnthropicClient
 func NewClient(apiKey string, opts ...ClientOption) *AnthropicClient {
     logMessage("Creating new AnthropicClient")
     client := &AnthropicClient{
-        apiKey:     apiKey,
-        httpClient: &http.Client{},
+        mu:          &sync.Mutex{},
+        apiKey:      apiKey,
+        baseURL:     defaultBaseURL,
+        apiVersion:  defaultAPIVersion,
+        userAgent:   defaultUserAgent,
+        httpClient:  &http.Client{},
+        now:         time.Now,
+        statsWindow: defaultStatsWindow,
+        httpTimeout: defaultHTTPTimeout,
+        backoff:     defaultBackoff,
+
+        maxToolIterations: defaultMaxToolIterations,
     }
     
     for _, opt := range opts {
         opt(client)
     }
+
+    if !client.customHTTPClient {
+        client.httpClient.Timeout = client.httpTimeout
+        client.httpClient.Transport = client.defaultTransport()
+    } else if client.transport != nil {
+        client.warn("WithTransport is ignored because WithHTTPClient was given")
+    }
+    client.applyMiddleware()
     
-    logJSON("Client configuration", map[string]interface{}{
+    client.logJSON("Client configuration", map[string]interface{}{
         "maxConvLength": client.maxConvLength,
         "hasDefaults":   len(client.defaultParams.Tools) > 0 || 
                         client.defaultParams.MaxTokens > 0 ||
@@ -52,39 +180,41 @@ func NewClient(apiKey string, opts ...ClientOption) *AnthropicClient {
 // ChatWithTools handles chat interactions with tool support
 // File: goanthropic.go
 
-// ChatWithTools handles chat interactions with tool support
-func (c *AnthropicClient) ChatWithTools(ctx context.Context, message string, params *types.MessageParams, handlers []types.ToolHandler) (*types.AnthropicResponse, error) {
-    // Use default params if none provided
-    finalParams := c.defaultParams
-    if params != nil {
-        // Merge any non-zero params from the provided params
-        if params.Model != "" {
-            finalParams.Model = params.Model
-        }
-        if params.MaxTokens != 0 {
-            finalParams.MaxTokens = params.MaxTokens
-        }
-        if params.Temperature != 0 {
-            finalParams.Temperature = params.Temperature
-        }
-        if params.TopP != 0 {
-            finalParams.TopP = params.TopP
-        }
-        if params.TopK != 0 {
-            finalParams.TopK = params.TopK
-        }
-        if params.Tools != nil {
-            finalParams.Tools = params.Tools
-        }
-        if params.ToolChoice != nil {
-            finalParams.ToolChoice = params.ToolChoice
-        }
+// ChatWithTools handles chat interactions with tool support. Each tool call is
+// dispatched to the handler whose GetTool().Name matches; handlers may be
+// structs holding state or plain functions wrapped in types.ToolHandlerFunc.
+//
+// A tool choice of "none" keeps the tools visible but asks for a text answer,
+// for example to have the model summarize what earlier calls gathered; no
+// handlers are needed. A forced choice ("any" or "tool") applies to the first
+// request only, after which the model chooses freely.
+//
+// If the call fails or ctx is cancelled after the model requested tools, each
+// unanswered tool call receives an error tool_result so the stored
+// conversation stays valid and can be continued.
+func (c *AnthropicClient) ChatWithTools(ctx context.Context, message string, params *types.MessageParams, handlers []types.ToolHandler) (response *types.AnthropicResponse, err error) {
+    if c.stateless {
+        return c.detached(nil).ChatWithTools(ctx, message, params, handlers)
     }
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+    defer func() {
+        if err != nil {
+            c.answerPendingToolUses(err)
+        }
+    }()
+
+    finalParams := c.mergeParams(params)
+    limit := c.conversationLimit(finalParams)
 
     // Validate the merged parameters
     if err := validateToolParams(&finalParams); err != nil {
         return nil, fmt.Errorf("invalid parameters: %w", err)
     }
+    registry, err := registerHandlers(handlers)
+    if err != nil {
+        return nil, fmt.Errorf("invalid handlers: %w", err)
+    }
 
     content := []types.MessageContent{{
         Type: types.ContentTypeText,
@@ -92,43 +222,82 @@ func (c *AnthropicClient) ChatWithTools(ctx context.Context, message string, par
     }}
 
     c.addMessageToConversation(types.RoleUser, content)
-    c.trimConversationHistory()
+    c.trimConversationHistory(limit)
+    c.resetToolRecords()
 
     // Main interaction loop
-    const maxIterations = 10
+    maxIterations := c.maxToolIterations
     iterations := 0
+    validationRetries := 0
+    nudged := false
+    loops := c.newToolLoopDetector()
 
     for {
         if iterations >= maxIterations {
-            return nil, fmt.Errorf("exceeded maximum number of tool call iterations (%d)", maxIterations)
+            return nil, &MaxIterationsError{Limit: maxIterations, Conversation: c.GetConversation()}
         }
 
-        reqBody := types.Request{
-            Model:       finalParams.Model,
-            System:      c.systemPrompt,
-            Messages:    c.conversation,
-            MaxTokens:   finalParams.MaxTokens,
-            Temperature: finalParams.Temperature,
-            TopP:        finalParams.TopP,
-            TopK:        finalParams.TopK,
-            Tools:       finalParams.Tools,
-            ToolChoice:  finalParams.ToolChoice,
+        toolChoice := finalParams.ToolChoice
+        if c.forceFinalAnswer && iterations >= maxIterations-1-c.finalAnswerLead {
+            // Out of tool rounds: the model must answer in text
+            toolChoice = types.NoneToolChoice()
         }
 
-        response, err := c.sendRequest(ctx, reqBody)
+        response, err := c.sendConversation(ctx, func() types.Request {
+            messages := c.conversationSnapshot()
+            return types.Request{
+                Model:         finalParams.Model,
+                System:        finalParams.System,
+                SystemBlocks:  finalParams.SystemBlocks,
+                Messages:      messages,
+                MaxTokens:     finalParams.MaxTokens,
+                Temperature:   finalParams.Temperature,
+                TopP:          finalParams.TopP,
+                TopK:          finalParams.TopK,
+                StopSequences: finalParams.StopSequences,
+                Metadata:      finalParams.Metadata,
+                Tools:         c.orderedTools(finalParams.Tools),
+                ToolChoice:    toolChoice,
+                Thinking:      finalParams.Thinking,
+            }
+        })
         if err != nil {
             return nil, err
         }
 
         // Add assistant's response to conversation
-        if len(response.Content) > 0 {
-            c.addMessageToConversation(types.RoleAssistant, response.Content)
-            c.trimConversationHistory()
+        if content := c.assistantContent(response.Content); len(content) > 0 {
+            c.addMessageToConversation(types.RoleAssistant, content)
+            c.trimConversationHistory(limit)
         }
 
         // Check if we need to execute tools
         if response.StopReason != types.StopReasonToolUse {
-            return response, nil
+            if !nudged && c.needsLongerResponse(response) {
+                nudged = true
+                c.appendUserText(minResponseNudge)
+                c.trimConversationHistory(limit)
+                iterations++
+                continue
+            }
+
+            verr := c.checkResponse(response)
+            if verr == nil {
+                return response, nil
+            }
+            if validationRetries >= c.validatorRetries {
+                return nil, verr
+            }
+            validationRetries++
+            c.addCorrectionPrompt(verr)
+            c.trimConversationHistory(limit)
+            iterations++
+            continue
+        }
+
+        // With tool_choice "none" the model may see tools but must not call them
+        if toolChoice != nil && toolChoice.Type == types.ToolChoiceNone {
+            return nil, fmt.Errorf("received tool_use stop reason while tool_choice is %q", types.ToolChoiceNone)
         }
 
         // Extract and process tool calls
@@ -137,48 +306,29 @@ func (c *AnthropicClient) ChatWithTools(ctx context.Context, message string, par
             return nil, fmt.Errorf("received tool_use stop reason but no valid tool calls found")
         }
 
-        // Execute tools and collect results
-        var resultContents []types.MessageContent
+        // Every call needs a handler before any tool runs
         for _, call := range toolCalls {
-            // Find matching handler
-            var handler types.ToolHandler
-            for _, h := range handlers {
-                if h.GetTool().Name == call.Name {
-                    handler = h
-                    break
-                }
-            }
-
-            if handler == nil {
+            if _, ok := registry[call.Name]; !ok {
                 return nil, fmt.Errorf("no handler for tool: %s", call.Name)
             }
+        }
 
-            // Execute tool
-            result, err := handler.Execute(ctx, call.Input)
-            if err != nil {
-                resultContents = append(resultContents, types.MessageContent{
-                    Type:      types.ContentTypeToolResult,
-                    ToolUseID: call.ID,
-                    Content:   fmt.Sprintf("Error executing tool: %v", err),
-                    IsError:   true,
-                })
-                continue
-            }
-
-            resultContents = append(resultContents, types.MessageContent{
-                Type:      types.ContentTypeToolResult,
-                ToolUseID: call.ID,
-                Content:   result,
-            })
+        repeated, err := loops.observe(toolCalls)
+        if err != nil {
+            return nil, err
         }
 
+        // Execute tools and collect results in call order
+        resultContents, _ := c.callTools(ctx, registry, toolCalls, iterations, response.Usage)
+        noteRepeats(resultContents, repeated)
+
         // Add tool results to conversation
         c.addMessageToConversation(types.RoleUser, resultContents)
-        c.trimConversationHistory()
+        c.trimConversationHistory(limit)
 
-        // Clear tool choice after first iteration
-        if iterations == 0 {
-            finalParams.ToolChoice = nil
+        // Release a forced choice after it has been honoured, keeping the parallel tool use setting
+        if choice := finalParams.ToolChoice; choice != nil && (choice.Type == types.ToolChoiceAny || choice.Type == types.ToolChoiceTool) {
+            finalParams.ToolChoice = &types.ToolChoice{Type: types.ToolChoiceAuto, DisableParallelToolUse: choice.DisableParallelToolUse}
         }
 
         iterations++
@@ -215,32 +365,14 @@ func extractToolCalls(resp *types.AnthropicResponse) []types.ToolUse {
     return calls
 }
 func (c *AnthropicClient) XChatWithTools(ctx context.Context, message string, params *types.MessageParams, handlers []types.ToolHandler) (*types.AnthropicResponse, error) {
-    // Use default params if none provided
-    finalParams := c.defaultParams
-    if params != nil {
-        // Merge any non-zero params from the provided params
-        if params.Model != "" {
-            finalParams.Model = params.Model
-        }
-        if params.MaxTokens != 0 {
-            finalParams.MaxTokens = params.MaxTokens
-        }
-        if params.Temperature != 0 {
-            finalParams.Temperature = params.Temperature
-        }
-        if params.TopP != 0 {
-            finalParams.TopP = params.TopP
-        }
-        if params.TopK != 0 {
-            finalParams.TopK = params.TopK
-        }
-        if params.Tools != nil {
-            finalParams.Tools = params.Tools
-        }
-        if params.ToolChoice != nil {
-            finalParams.ToolChoice = params.ToolChoice
-        }
+    if c.stateless {
+        return c.detached(nil).XChatWithTools(ctx, message, params, handlers)
     }
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    finalParams := c.mergeParams(params)
+    limit := c.conversationLimit(finalParams)
 
     // Validate the merged parameters
     if err := validateToolParams(&finalParams); err != nil {
@@ -253,160 +385,527 @@ func (c *AnthropicClient) XChatWithTools(ctx context.Context, message string, pa
     }}
 
     c.addMessageToConversation(types.RoleUser, content)
-    c.trimConversationHistory()
+    c.trimConversationHistory(limit)
 
-    reqBody := types.Request{
-        Model:       finalParams.Model,
-        System:      c.systemPrompt,
-        Messages:    c.conversation,
-        MaxTokens:   finalParams.MaxTokens,
-        Temperature: finalParams.Temperature,
-        TopP:        finalParams.TopP,
-        TopK:        finalParams.TopK,
-        Tools:       finalParams.Tools,
-        ToolChoice:  finalParams.ToolChoice,
+    send := func() (*types.AnthropicResponse, error) {
+        response, err := c.sendConversation(ctx, func() types.Request {
+            messages := c.conversationSnapshot()
+            return types.Request{
+                Model:         finalParams.Model,
+                System:        finalParams.System,
+                SystemBlocks:  finalParams.SystemBlocks,
+                Messages:      messages,
+                MaxTokens:     finalParams.MaxTokens,
+                Temperature:   finalParams.Temperature,
+                TopP:          finalParams.TopP,
+                TopK:          finalParams.TopK,
+                StopSequences: finalParams.StopSequences,
+                Metadata:      finalParams.Metadata,
+                Tools:         c.orderedTools(finalParams.Tools),
+                ToolChoice:    finalParams.ToolChoice,
+                Thinking:      finalParams.Thinking,
+            }
+        })
+        if err != nil {
+            return nil, err
+        }
+
+        if content := c.assistantContent(response.Content); len(content) > 0 {
+            c.addMessageToConversation(types.RoleAssistant, content)
+            c.trimConversationHistory(limit)
+        }
+        return response, nil
     }
 
-    response, err := c.sendRequest(ctx, reqBody)
+    response, err := send()
     if err != nil {
         return nil, err
     }
 
-    if len(response.Content) > 0 {
-        c.addMessageToConversation(types.RoleAssistant, response.Content)
-        c.trimConversationHistory()
-    }
-
-    return response, nil
+    return c.validateResponse(response, limit, send)
 }
 
 // ChatMe handles basic chat interactions without tools
 func (c *AnthropicClient) ChatMe(ctx context.Context, message string, params *types.MessageParams) (*types.AnthropicResponse, error) {
-    finalParams := c.defaultParams
-    if params != nil {
-        if params.Model != "" {
-            finalParams.Model = params.Model
-        }
-        if params.MaxTokens != 0 {
-            finalParams.MaxTokens = params.MaxTokens
-        }
-        if params.Temperature != 0 {
-            finalParams.Temperature = params.Temperature
-        }
-        if params.TopP != 0 {
-            finalParams.TopP = params.TopP
-        }
-        if params.TopK != 0 {
-            finalParams.TopK = params.TopK
-        }
-    }
-
     content := []types.MessageContent{{
         Type: types.ContentTypeText,
         Text: message,
     }}
+    return c.ChatMessage(ctx, content, params)
+}
+
+// ChatMessage sends a user message made of the given content blocks, such as
+// text with images or a crafted tool_result follow-up, and stores the reply.
+// ChatMe, ChatWithImage and ChatWithDocument are built on it.
+//
+// A reply with no content to store, such as an empty or whitespace-only
+// answer, returns an error matching ErrEmptyResponse and removes the user turn
+// again, so the conversation never holds two user turns in a row.
+func (c *AnthropicClient) ChatMessage(ctx context.Context, content []types.MessageContent, params *types.MessageParams) (*types.AnthropicResponse, error) {
+    if len(content) == 0 {
+        return nil, fmt.Errorf("message content cannot be empty")
+    }
+    if c.stateless {
+        return c.detached(nil).ChatMessage(ctx, content, params)
+    }
 
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    finalParams := c.mergeParams(params)
+    limit := c.conversationLimit(finalParams)
+    if err := validatePrefill(finalParams); err != nil {
+        return nil, fmt.Errorf("invalid parameters: %w", err)
+    }
+    if err := c.checkImageLimit(content); err != nil {
+        return nil, err
+    }
+
+    prior := c.userTurnLength()
     c.addMessageToConversation(types.RoleUser, content)
-    c.trimConversationHistory()
+    c.trimConversationHistory(limit)
 
-    reqBody := types.Request{
-        Model:       finalParams.Model,
-        System:      c.systemPrompt,
-        Messages:    c.conversation,
-        MaxTokens:   finalParams.MaxTokens,
-        Temperature: finalParams.Temperature,
-        TopP:        finalParams.TopP,
-        TopK:        finalParams.TopK,
+    send := func() (*types.AnthropicResponse, error) {
+        response, err := c.sendConversation(ctx, func() types.Request {
+            messages := appendPrefill(c.conversationSnapshot(), finalParams.Prefill)
+            return types.Request{
+                Model:         finalParams.Model,
+                System:        finalParams.System,
+                SystemBlocks:  finalParams.SystemBlocks,
+                Messages:      messages,
+                MaxTokens:     finalParams.MaxTokens,
+                Temperature:   finalParams.Temperature,
+                TopP:          finalParams.TopP,
+                TopK:          finalParams.TopK,
+                StopSequences: finalParams.StopSequences,
+                Metadata:      finalParams.Metadata,
+                Thinking:      finalParams.Thinking,
+            }
+        })
+        if err != nil {
+            return nil, err
+        }
+
+        if content := withPrefill(c.assistantContent(response.Content), finalParams.Prefill); len(content) > 0 {
+            c.addMessageToConversation(types.RoleAssistant, content)
+            c.trimConversationHistory(limit)
+        }
+        return response, nil
+    }
+
+    response, err := send()
+    if err != nil {
+        return nil, err
     }
 
-    response, err := c.sendRequest(ctx, reqBody)
+    response, err = c.validateResponse(response, limit, send)
     if err != nil {
         return nil, err
     }
+    if len(withPrefill(c.assistantContent(response.Content), finalParams.Prefill)) == 0 {
+        c.removeUnansweredTurn(prior)
+        return nil, fmt.Errorf("%w (stop reason %s)", ErrEmptyResponse, response.StopReason)
+    }
+    return c.autoContinue(ctx, response, params)
+}
+
+// prepareRequest applies client-wide request settings, checks the result and
+// records it for cache analysis and prompt logging before it is sent
+func (c *AnthropicClient) prepareRequest(reqBody types.Request) (types.Request, error) {
+    reqBody, err := c.applyRequestSettings(reqBody)
+    if err != nil {
+        return reqBody, err
+    }
+    c.observeCacheability(reqBody)
+    c.logPrompt(reqBody)
+    return reqBody, nil
+}
 
-    if len(response.Content) > 0 {
-        c.addMessageToConversation(types.RoleAssistant, response.Content)
-        c.trimConversationHistory()
+// applyRequestSettings adds the configured cache markers and enforces the
+// thinking, cache and image limits
+func (c *AnthropicClient) applyRequestSettings(reqBody types.Request) (types.Request, error) {
+    reqBody = c.applyCacheMarkers(reqBody)
+    if err := c.validateLongContext(reqBody.Model); err != nil {
+        return reqBody, fmt.Errorf("invalid request: %w", err)
     }
+    if err := c.checkSampling(reqBody); err != nil {
+        return reqBody, fmt.Errorf("invalid request: %w", err)
+    }
+    if err := validateThinking(reqBody); err != nil {
+        return reqBody, fmt.Errorf("invalid request: %w", err)
+    }
+    if err := validateCacheControl(reqBody); err != nil {
+        return reqBody, fmt.Errorf("invalid request: %w", err)
+    }
+    return c.limitImages(reqBody)
+}
+
+// WithStrictSampling rejects requests that set both temperature and top_p,
+// which the API recommends against. Without it such requests are sent with a
+// warning.
+func WithStrictSampling() ClientOption {
+    return func(c *AnthropicClient) {
+        c.strictSampling = true
+    }
+}
+
+// checkSampling warns about, or with WithStrictSampling rejects, a request
+// that sets both temperature and top_p
+func (c *AnthropicClient) checkSampling(req types.Request) error {
+    if req.Temperature == nil || req.TopP == nil {
+        return nil
+    }
+    if c.strictSampling {
+        return fmt.Errorf("temperature and top_p should not both be set")
+    }
+    c.warn("Request sets both temperature (%g) and top_p (%g); set only one", *req.Temperature, *req.TopP)
+    return nil
+}
 
-    return response, nil
+// validateThinking checks the extended thinking budget against the API limits
+func validateThinking(req types.Request) error {
+    if req.Thinking == nil {
+        return nil
+    }
+    if req.Thinking.BudgetTokens < 1024 {
+        return fmt.Errorf("thinking budget must be at least 1024 tokens, got %d", req.Thinking.BudgetTokens)
+    }
+    if req.Thinking.BudgetTokens >= req.MaxTokens {
+        return fmt.Errorf("thinking budget (%d) must be less than max_tokens (%d)", req.Thinking.BudgetTokens, req.MaxTokens)
+    }
+    return nil
 }
 
 // sendRequest handles the HTTP communication with the Anthropic API
 func (c *AnthropicClient) sendRequest(ctx context.Context, reqBody types.Request) (*types.AnthropicResponse, error) {
-    logMessage("Preparing API request")
-    logJSON("Request payload", reqBody)
+    ctx, cancel := c.withRequestDeadline(ctx)
+    defer cancel()
 
-    jsonData, err := json.Marshal(reqBody)
-    if err != nil {
-        logMessage("Error marshaling request: %v", err)
-        return nil, fmt.Errorf("error marshaling request: %w", err)
-    }
+    c.logMessage("Preparing API request")
+    c.logJSON("Request payload", reqBody)
 
-    req, err := http.NewRequestWithContext(ctx, "POST", defaultAPIEndpoint, bytes.NewBuffer(jsonData))
+    if err := c.validateModel(ctx, reqBody.Model); err != nil {
+        return nil, err
+    }
+    reqBody, err := c.prepareRequest(reqBody)
     if err != nil {
-        logMessage("Error creating HTTP request: %v", err)
-        return nil, fmt.Errorf("error creating request: %w", err)
+        return nil, err
     }
 
-    req.Header.Set("Content-Type", "application/json")
-    req.Header.Set("anthropic-version", "2023-06-01")
-    req.Header.Set("x-api-key", c.apiKey)
+    for attempt := 0; ; attempt++ {
+        body, err := c.postJSON(ctx, c.endpoint(messagesPath), reqBody, requestBetas(reqBody))
+        if err != nil {
+            c.recordError(err)
+            return nil, err
+        }
 
-    logMessage("Sending request to Anthropic API")
-    resp, err := c.httpClient.Do(req)
-    if err != nil {
-        logMessage("API request failed: %v", err)
-        return nil, fmt.Errorf("error sending request: %w", err)
+        var anthropicResp types.AnthropicResponse
+        if err := json.Unmarshal(body, &anthropicResp); err != nil {
+            c.logError("Error parsing response JSON: %v", err)
+            // A truncated 200 body usually succeeds when requested again
+            if attempt < c.malformedRetries {
+                c.logMessage("Retrying malformed response (attempt %d of %d)", attempt+1, c.malformedRetries)
+                if err := sleepContext(ctx, c.backoff.Delay(attempt)); err != nil {
+                    return nil, err
+                }
+                continue
+            }
+            err = fmt.Errorf("error parsing response: %w", err)
+            c.recordError(err)
+            return nil, err
+        }
+
+        c.logJSON("API response", anthropicResp)
+        c.recordUsage(anthropicResp.Usage)
+        return &anthropicResp, nil
     }
-    defer resp.Body.Close()
+}
 
-    body, err := ioutil.ReadAll(resp.Body)
+// postJSON marshals payload, posts it to endpoint and returns the body of a
+// successful response. Non-200 responses are converted into errors.
+func (c *AnthropicClient) postJSON(ctx context.Context, endpoint string, payload interface{}, betas []string) ([]byte, error) {
+    jsonData, err := json.Marshal(payload)
     if err != nil {
-        logMessage("Error reading response body: %v", err)
-        return nil, fmt.Errorf("error reading response: %w", err)
+        c.logError("Error marshaling request: %v", err)
+        return nil, fmt.Errorf("error marshaling request: %w", err)
     }
+    return c.doRequest(ctx, http.MethodPost, endpoint, jsonData, betas)
+}
+
+// getJSON fetches endpoint and returns the body of a successful response
+func (c *AnthropicClient) getJSON(ctx context.Context, endpoint string) ([]byte, error) {
+    return c.doRequest(ctx, http.MethodGet, endpoint, nil, nil)
+}
+
+// doRequest sends a signed API request and returns the body of a successful
+// response. Non-200 responses are converted into errors.
+func (c *AnthropicClient) doRequest(ctx context.Context, method, endpoint string, jsonData []byte, betas []string) ([]byte, error) {
+    for attempt := 0; ; attempt++ {
+        req, err := c.newAPIRequest(ctx, method, endpoint, jsonData, betas)
+        if err != nil {
+            c.logError("Error creating HTTP request: %v", err)
+            return nil, fmt.Errorf("error creating request: %w", err)
+        }
+
+        c.logMessage("Sending request to Anthropic API")
+        resp, err := c.httpClient.Do(req)
+        if err != nil {
+            c.logError("API request failed: %v", err)
+            return nil, fmt.Errorf("error sending request: %w", err)
+        }
+
+        body, err := ioutil.ReadAll(resp.Body)
+        resp.Body.Close()
+        if err != nil {
+            c.logError("Error reading response body: %v", err)
+            return nil, fmt.Errorf("error reading response: %w", err)
+        }
+        c.recordResponse(resp, body)
 
-    if resp.StatusCode != http.StatusOK {
-        logMessage("Received error response (status %d)", resp.StatusCode)
-        var errorResp struct {
-            Error struct {
-                Type    string `json:"type"`
-                Message string `json:"message"`
-            } `json:"error"`
+        if resp.StatusCode == http.StatusRequestEntityTooLarge {
+            c.logMessage("Request rejected as too large (%d bytes)", len(jsonData))
         }
-        if err := json.Unmarshal(body, &errorResp); err != nil {
-            logMessage("Failed to parse error response: %v", err)
-            return nil, fmt.Errorf("error response status %d: %s", resp.StatusCode, body)
+        if resp.StatusCode == http.StatusOK {
+            return body, nil
         }
-        logMessage("API error: %s - %s", errorResp.Error.Type, errorResp.Error.Message)
-        return nil, fmt.Errorf("API error: %s - %s", errorResp.Error.Type, errorResp.Error.Message)
+        if attempt < c.retries && isRetryableStatus(resp.StatusCode) {
+            c.logMessage("Retrying after status %d (attempt %d of %d)", resp.StatusCode, attempt+1, c.retries)
+            if err := sleepContext(ctx, c.backoff.Delay(attempt)); err != nil {
+                return nil, err
+            }
+            continue
+        }
+        return nil, c.statusError(resp.StatusCode, body)
     }
+}
 
-    var anthropicResp types.AnthropicResponse
-    if err := json.Unmarshal(body, &anthropicResp); err != nil {
-        logMessage("Error parsing response JSON: %v", err)
-        return nil, fmt.Errorf("error parsing response: %w", err)
+// statusError converts a non-200 API response into an error
+func (c *AnthropicClient) statusError(statusCode int, body []byte) error {
+    if statusCode == http.StatusRequestEntityTooLarge {
+        return &RequestTooLargeError{StatusCode: statusCode, Message: errorMessage(body)}
+    }
+
+    c.logMessage("Received error response (status %d)", statusCode)
+    var errorResp struct {
+        Error struct {
+            Type    string `json:"type"`
+            Message string `json:"message"`
+        } `json:"error"`
+    }
+    if err := json.Unmarshal(body, &errorResp); err != nil {
+        c.logError("Failed to parse error response: %v", err)
+        return &APIError{StatusCode: statusCode, Message: string(body)}
+    }
+    c.logError("API error: %s - %s", errorResp.Error.Type, errorResp.Error.Message)
+    return &APIError{StatusCode: statusCode, Type: errorResp.Error.Type, Message: errorResp.Error.Message}
+}
+
+// newAPIRequest builds a signed request to the API with the standard headers.
+// It must be called once per attempt so that every attempt is signed.
+func (c *AnthropicClient) newAPIRequest(ctx context.Context, method, endpoint string, body []byte, betas []string) (*http.Request, error) {
+    if c.configErr != nil {
+        return nil, c.configErr
+    }
+    req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewBuffer(body))
+    if err != nil {
+        return nil, err
+    }
+
+    req.Header.Set("Content-Type", "application/json")
+    req.Header.Set("anthropic-version", c.apiVersion)
+    req.Header.Set("User-Agent", c.userAgent)
+    req.Header.Set("x-api-key", c.requestAPIKey(ctx))
+    if betas := c.withBetaFeatures(betas); len(betas) > 0 {
+        req.Header.Set("anthropic-beta", strings.Join(betas, ","))
     }
 
-    logJSON("API response", anthropicResp)
-    return &anthropicResp, nil
+    if c.requestSigner != nil {
+        c.requestSigner(body, req.Header)
+    }
+    return req, nil
 }
 
 // Conversation management methods
 func (c *AnthropicClient) addMessageToConversation(role string, content []types.MessageContent) {
-    logMessage("Adding message to conversation (role: %s)", role)
-    c.conversation = append(c.conversation, types.Message{
-        Role:    role,
-        Content: content,
-    })
+    c.mu.Lock()
+    defer c.unlock()
+    c.appendMessage(role, content)
+}
+
+// appendMessage adds a message to the conversation. A message with the same
+// role as the last one is merged into it so that roles always alternate, and
+// the merged message takes the new CreatedAt so its fresh content is not
+// evicted by age. The caller must hold c.mu.
+func (c *AnthropicClient) appendMessage(role string, content []types.MessageContent) {
+    if n := len(c.conversation); n > 0 && c.conversation[n-1].Role == role {
+        c.logMessage("Merging message into previous turn (role: %s)", role)
+        // Copy so requests already built from the conversation are not affected
+        last := &c.conversation[n-1]
+        last.Content = append(append([]types.MessageContent(nil), last.Content...), content...)
+        last.CreatedAt = c.now()
+        c.notifyMessageChange(types.ConversationEventEdit, *last)
+        return
+    }
+
+    c.logMessage("Adding message to conversation (role: %s)", role)
+    msg := types.Message{
+        Role:      role,
+        Content:   content,
+        CreatedAt: c.now(),
+    }
+    if c.messageIDs {
+        msg.ID = newMessageID()
+    }
+    c.conversation = append(c.conversation, msg)
+    c.notifyMessageChange(types.ConversationEventAppend, msg)
+}
+
+// trimConversationHistory drops expired messages, keeps at most limit
+// messages and then applies the token budget; a limit of zero keeps every
+// message. Trimming never separates a tool_use from its tool_result: the
+// history is cut at the nearest safe boundary after the limit, or before it
+// when no later boundary exists.
+func (c *AnthropicClient) trimConversationHistory(limit int) {
+    c.mu.Lock()
+    defer c.unlock()
+
+    c.evictExpiredMessages()
+    c.trimToLength(limit)
+    c.trimToTokenBudget()
+}
+
+// trimToLength keeps at most limit messages. The caller must hold c.mu.
+func (c *AnthropicClient) trimToLength(limit int) {
+    if limit <= 0 || len(c.conversation) <= limit {
+        return
+    }
+
+    c.logMessage("Trimming conversation to max length: %d", limit)
+    excess := len(c.conversation) - limit
+    removed := safeStartIndex(c.conversation, excess)
+    if removed >= len(c.conversation) {
+        // Mid tool loop there may be no boundary ahead; keep a few extra messages instead
+        removed = excess - 1
+        for removed > 0 && safeStartIndex(c.conversation, removed) != removed {
+            removed--
+        }
+    }
+    if removed > 0 {
+        c.conversation = c.conversation[removed:]
+        c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventTrim, Removed: removed})
+    }
+
+    // A long tool loop has no boundary after its opening user turn; drop
+    // whole tool exchanges behind that turn instead
+    if len(c.conversation) > limit {
+        c.dropToolExchanges(len(c.conversation) - limit)
+    }
+}
+
+// dropToolExchanges removes the oldest assistant tool_use turns that follow
+// the opening user turn, each together with the tool_result turn answering
+// it, until at least excess messages are gone. The most recent exchange is
+// always kept. The caller must hold c.mu.
+func (c *AnthropicClient) dropToolExchanges(excess int) {
+    end := 1
+    for end-1 < excess && end+2 < len(c.conversation) {
+        call, answer := c.conversation[end], c.conversation[end+1]
+        if call.Role != types.RoleAssistant || !hasContentType(call.Content, types.ContentTypeToolUse) ||
+            answer.Role != types.RoleUser || !hasContentType(answer.Content, types.ContentTypeToolResult) {
+            break
+        }
+        end += 2
+    }
+    removed := end - 1
+    if removed == 0 {
+        return
+    }
+
+    kept := make([]types.Message, 0, len(c.conversation)-removed)
+    kept = append(kept, c.conversation[0])
+    c.conversation = append(kept, c.conversation[end:]...)
+    c.logMessage("Dropped %d messages of earlier tool exchanges", removed)
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventTrim, Removed: removed})
 }
 
-func (c *AnthropicClient) trimConversationHistory() {
-    if c.maxConvLength > 0 && len(c.conversation) > c.maxConvLength {
-        logMessage("Trimming conversation to max length: %d", c.maxConvLength)
-        c.conversation = c.conversation[len(c.conversation)-c.maxConvLength:]
+// conversationLimit returns the message limit that applies to a call made with params
+func (c *AnthropicClient) conversationLimit(params types.MessageParams) int {
+    switch {
+    case params.ConversationLimit < 0:
+        return 0
+    case params.ConversationLimit > 0:
+        return params.ConversationLimit
+    default:
+        return c.maxConvLength
+    }
+}
+
+// mergeParams overlays the non-zero fields of params on the client defaults.
+// The system prompt is resolved as described on WithSystemPrompt, and tools
+// without a tool choice get an auto choice.
+func (c *AnthropicClient) mergeParams(params *types.MessageParams) types.MessageParams {
+    c.mu.Lock()
+    finalParams := c.defaultParams
+    if c.systemPrompt != "" {
+        finalParams.System = c.systemPrompt
+        finalParams.SystemBlocks = nil
+    }
+    c.mu.Unlock()
+    if params == nil {
+        params = &types.MessageParams{}
+    }
+
+    if params.System != "" {
+        finalParams.System = params.System
+        finalParams.SystemBlocks = nil
     }
+    if len(params.SystemBlocks) > 0 {
+        finalParams.SystemBlocks = params.SystemBlocks
+    }
+    if len(finalParams.SystemBlocks) > 0 {
+        finalParams.System = types.SystemText(finalParams.SystemBlocks)
+    }
+
+    if params.Model != "" {
+        finalParams.Model = params.Model
+    }
+    if params.MaxTokens != 0 {
+        finalParams.MaxTokens = params.MaxTokens
+    }
+    if params.Temperature != nil {
+        finalParams.Temperature = params.Temperature
+    }
+    if params.TopP != nil {
+        finalParams.TopP = params.TopP
+    }
+    if params.TopK != 0 {
+        finalParams.TopK = params.TopK
+    }
+    if len(params.StopSequences) > 0 {
+        finalParams.StopSequences = params.StopSequences
+    }
+    if params.Metadata != nil {
+        finalParams.Metadata = params.Metadata
+    }
+    if params.Tools != nil {
+        finalParams.Tools = params.Tools
+    }
+    if params.ToolChoice != nil {
+        finalParams.ToolChoice = params.ToolChoice
+    }
+    if params.Thinking != nil {
+        finalParams.Thinking = params.Thinking
+    }
+    if params.Prefill != "" {
+        finalParams.Prefill = params.Prefill
+    }
+    if params.ConversationLimit != 0 {
+        finalParams.ConversationLimit = params.ConversationLimit
+    }
+    if len(finalParams.Tools) > 0 && finalParams.ToolChoice == nil {
+        finalParams.ToolChoice = &types.ToolChoice{Type: types.ToolChoiceAuto}
+    }
+    return finalParams
 }
 
 // Client options
@@ -418,30 +917,173 @@ func WithMaxConversationLength(length int) ClientOption {
     }
 }
 
+// WithSystemPrompt sets the system prompt sent with every request.
+//
+// The system prompt for a call is chosen in this order: the System field of
+// the params passed to the call, then the prompt set with WithSystemPrompt or
+// SetSystemPrompt, then the System field of WithDefaultParams. At each level
+// SystemBlocks, when set, takes the place of System.
+func WithSystemPrompt(prompt string) ClientOption {
+    return func(c *AnthropicClient) {
+        c.systemPrompt = prompt
+    }
+}
+
+// SetSystemPrompt replaces the client's system prompt for subsequent calls.
+// An empty prompt falls back to the System field of the default params.
+func (c *AnthropicClient) SetSystemPrompt(prompt string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.systemPrompt = prompt
+}
+
+// WithDefaultParams sets the parameters used when a call leaves them unset.
+// A model, max tokens or tool choice already set with WithModel,
+// WithMaxTokensDefault or WithDefaultToolChoice is kept when params leaves it
+// empty, so the options compose in any order.
 func WithDefaultParams(params types.MessageParams) ClientOption {
     return func(c *AnthropicClient) {
+        if params.Model == "" {
+            params.Model = c.defaultParams.Model
+        }
+        if params.MaxTokens == 0 {
+            params.MaxTokens = c.defaultParams.MaxTokens
+        }
+        if params.ToolChoice == nil {
+            params.ToolChoice = c.defaultParams.ToolChoice
+        }
         c.defaultParams = params
     }
 }
 
+// WithDefaultToolChoice sets the tool choice used when a call offers tools
+// without choosing. Without it, tools that come with no explicit choice
+// default to auto.
+func WithDefaultToolChoice(choice types.ToolChoice) ClientOption {
+    return func(c *AnthropicClient) {
+        c.defaultParams.ToolChoice = &choice
+    }
+}
+
+// WithModel sets the default model without changing the other default params
+func WithModel(model string) ClientOption {
+    return func(c *AnthropicClient) {
+        if model != "" {
+            c.defaultParams.Model = model
+        }
+    }
+}
+
+// WithMaxTokensDefault sets the default max_tokens for responses without
+// changing the other default params. Not to be confused with WithMaxTokens,
+// which limits the size of the stored conversation.
+func WithMaxTokensDefault(maxTokens int) ClientOption {
+    return func(c *AnthropicClient) {
+        if maxTokens > 0 {
+            c.defaultParams.MaxTokens = maxTokens
+        }
+    }
+}
+
+// WithHTTPClient makes the client send requests with client, which is used as
+// configured: WithTimeout, WithTransport and WithForceHTTP1 do not change it.
 func WithHTTPClient(client *http.Client) ClientOption {
     return func(c *AnthropicClient) {
         if client != nil {
             c.httpClient = client
+            c.customHTTPClient = true
         }
     }
 }
 
-// Parameter validation
+// WithAutoToolChoiceNoneOnFinalAnswer sets tool_choice to "none" as ChatWithTools
+// approaches its iteration limit, so the loop ends with a text answer instead of
+// an error. With lead set to 0 only the last allowed iteration is affected; a lead
+// of 1 starts on the second-to-last iteration, and so on.
+func WithAutoToolChoiceNoneOnFinalAnswer(lead int) ClientOption {
+    return func(c *AnthropicClient) {
+        if lead >= 0 {
+            c.forceFinalAnswer = true
+            c.finalAnswerLead = lead
+        }
+    }
+}
+
+// WithRequestSigner sets a hook that can add headers computed over the request
+// body, such as an HMAC signature required by a proxy. It runs after all
+// standard headers are set and before every attempt is sent.
+func WithRequestSigner(signer func(body []byte, headers http.Header)) ClientOption {
+    return func(c *AnthropicClient) {
+        c.requestSigner = signer
+    }
+}
+
+// WithWarningHandler sets the callback that receives non-fatal warnings.
+// Without a handler, warnings are written to the log.
+func WithWarningHandler(handler func(string)) ClientOption {
+    return func(c *AnthropicClient) {
+        c.warningHandler = handler
+    }
+}
+
+// warn reports a non-fatal condition to the warning handler, or the log if none is set
+func (c *AnthropicClient) warn(format string, args ...interface{}) {
+    msg := fmt.Sprintf(format, args...)
+    if c.warningHandler != nil {
+        c.warningHandler(msg)
+        return
+    }
+    if c.logger != nil {
+        c.logger.Info("Warning: " + c.maskKey(msg))
+        return
+    }
+    logMessage("Warning: %s", c.maskKey(msg))
+}
+
+// validateToolParams checks the tools and tool choice of merged parameters.
+// Calls without tools need no tool choice; once tools are set a valid choice
+// is required, and a "tool" choice must name one of them.
 func validateToolParams(params *types.MessageParams) error {
     if params == nil {
         return fmt.Errorf("message parameters cannot be nil")
     }
-    if params.Tools == nil {
-        return fmt.Errorf("tools cannot be nil")
-    }
     if params.ToolChoice == nil {
-        return fmt.Errorf("tool choice cannot be nil")
+        if len(params.Tools) > 0 {
+            return fmt.Errorf("tool choice is required when tools are set")
+        }
+        return nil
+    }
+
+    choice := params.ToolChoice
+    switch choice.Type {
+    case types.ToolChoiceNone:
+        // A "none" turn only shows tools to the model, so neither tools nor handlers are required
+        if choice.Name != "" || choice.DisableParallelToolUse {
+            return fmt.Errorf("tool choice %q does not accept a name or disable_parallel_tool_use", choice.Type)
+        }
+        return nil
+    case types.ToolChoiceAuto, types.ToolChoiceAny:
+        if choice.Name != "" {
+            return fmt.Errorf("tool choice %q does not accept a name", choice.Type)
+        }
+    case types.ToolChoiceTool:
+        if choice.Name == "" {
+            return fmt.Errorf("tool choice %q requires a tool name", choice.Type)
+        }
+    default:
+        return fmt.Errorf("unsupported tool choice type %q", choice.Type)
+    }
+
+    if len(params.Tools) == 0 {
+        return fmt.Errorf("tool choice %q requires at least one tool", choice.Type)
+    }
+    if choice.Type == types.ToolChoiceTool {
+        for _, tool := range params.Tools {
+            if tool.Name == choice.Name {
+                return nil
+            }
+        }
+        return fmt.Errorf("tool choice names unknown tool %q", choice.Name)
     }
     return nil
 }
@@ -0,0 +1,44 @@
+package goanthropic
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestValidateToolParams(t *testing.T) {
+    search := []types.Tool{{Name: "search"}}
+    tests := []struct {
+        name    string
+        params  *types.MessageParams
+        wantErr string
+    }{
+        {name: "nil params", params: nil, wantErr: "cannot be nil"},
+        {name: "nil tools", params: &types.MessageParams{}},
+        {name: "tools without choice", params: &types.MessageParams{Tools: search}, wantErr: "tool choice is required"},
+        {name: "auto", params: &types.MessageParams{Tools: search, ToolChoice: &types.ToolChoice{Type: types.ToolChoiceAuto}}},
+        {name: "any", params: &types.MessageParams{Tools: search, ToolChoice: &types.ToolChoice{Type: types.ToolChoiceAny}}},
+        {name: "named tool", params: &types.MessageParams{Tools: search, ToolChoice: &types.ToolChoice{Type: types.ToolChoiceTool, Name: "search"}}},
+        {name: "none without tools", params: &types.MessageParams{ToolChoice: types.NoneToolChoice()}},
+        {name: "choice without tools", params: &types.MessageParams{ToolChoice: &types.ToolChoice{Type: types.ToolChoiceAuto}}, wantErr: "requires at least one tool"},
+        {name: "unknown type", params: &types.MessageParams{Tools: search, ToolChoice: &types.ToolChoice{Type: "sometimes"}}, wantErr: `unsupported tool choice type "sometimes"`},
+        {name: "tool without name", params: &types.MessageParams{Tools: search, ToolChoice: &types.ToolChoice{Type: types.ToolChoiceTool}}, wantErr: "requires a tool name"},
+        {name: "unknown tool", params: &types.MessageParams{Tools: search, ToolChoice: &types.ToolChoice{Type: types.ToolChoiceTool, Name: "fetch"}}, wantErr: `unknown tool "fetch"`},
+        {name: "auto with name", params: &types.MessageParams{Tools: search, ToolChoice: &types.ToolChoice{Type: types.ToolChoiceAuto, Name: "search"}}, wantErr: "does not accept a name"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := validateToolParams(tt.params)
+            if tt.wantErr == "" {
+                if err != nil {
+                    t.Fatalf("validateToolParams: %v", err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+            }
+        })
+    }
+}
@@ -0,0 +1,214 @@
+package goanthropic_test
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestConversationLimitPerCall(t *testing.T) {
+    srv := anthropictest.NewServer(
+        anthropictest.TextResponse("a"),
+        anthropictest.TextResponse("b"),
+        anthropictest.TextResponse("c"),
+        anthropictest.TextResponse("d"),
+        anthropictest.TextResponse("e"),
+        anthropictest.TextResponse("f"),
+    )
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithMaxConversationLength(3))
+
+    full := &types.MessageParams{ConversationLimit: types.NoConversationLimit}
+    for _, message := range []string{"one", "two", "three"} {
+        if _, err := client.ChatMe(context.Background(), message, full); err != nil {
+            t.Fatalf("ChatMe: %v", err)
+        }
+    }
+    req, _ := srv.LastRequest()
+    if got := len(req.Messages); got != 5 {
+        t.Fatalf("bypass call sent %d messages, want the full history of 5", got)
+    }
+
+    if _, err := client.ChatMe(context.Background(), "four", &types.MessageParams{ConversationLimit: 1}); err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    req, _ = srv.LastRequest()
+    if got := len(req.Messages); got != 1 {
+        t.Errorf("call with a limit of 1 sent %d messages", got)
+    }
+
+    // The client setting still applies to later calls
+    chatTurns(t, client, "five", "six")
+    req, _ = srv.LastRequest()
+    if got := len(req.Messages); got != 3 {
+        t.Errorf("default call sent %d messages, want 3", got)
+    }
+}
+
+func TestConversationObserver(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("a"), anthropictest.TextResponse("b"))
+    defer srv.Close()
+    var client *goanthropic.AnthropicClient
+    var events []types.ConversationEvent
+    client = srv.Client(
+        goanthropic.WithMaxConversationLength(2),
+        goanthropic.WithConversationObserver(func(event types.ConversationEvent) {
+            // Reading the conversation here deadlocks if observers run under the lock
+            if got := len(client.GetConversation()); got != event.Length {
+                t.Errorf("%s event reports length %d, conversation has %d", event.Type, event.Length, got)
+            }
+            events = append(events, event)
+        }),
+    )
+
+    chatTurns(t, client, "one", "two")
+    client.ResetConversation()
+
+    var appends, trims, clears int
+    for _, event := range events {
+        switch event.Type {
+        case types.ConversationEventAppend:
+            appends++
+            if event.Message == nil {
+                t.Error("append event without the message")
+            }
+        case types.ConversationEventTrim:
+            trims++
+            if event.Removed == 0 {
+                t.Error("trim event removed nothing")
+            }
+        case types.ConversationEventClear:
+            clears++
+        }
+    }
+    if appends != 4 || trims == 0 || clears != 1 {
+        t.Errorf("got %d appends, %d trims and %d clears, want 4, at least 1 and 1", appends, trims, clears)
+    }
+    if first := events[0]; first.Type != types.ConversationEventAppend || first.Message.Content[0].Text != "one" {
+        t.Errorf("first event = %+v, want the user message appended", first)
+    }
+}
+
+func TestConcurrentChatMe(t *testing.T) {
+    const calls = 50
+    responses := make([]types.AnthropicResponse, calls)
+    for i := range responses {
+        responses[i] = anthropictest.TextResponse("ok")
+    }
+    srv := anthropictest.NewServer(responses...)
+    defer srv.Close()
+    client := srv.Client()
+
+    var wg sync.WaitGroup
+    errs := make(chan error, calls)
+    for i := 0; i < calls; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            if _, err := client.ChatMe(context.Background(), fmt.Sprintf("message %d", i), nil); err != nil {
+                errs <- err
+            }
+            // Readers and configuration changes run alongside the calls
+            client.GetConversation()
+            client.SetSystemPrompt(fmt.Sprintf("prompt %d", i))
+            client.SessionUsage()
+        }(i)
+    }
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        t.Errorf("ChatMe: %v", err)
+    }
+
+    if got := len(srv.Requests()); got != calls {
+        t.Errorf("sent %d requests, want %d", got, calls)
+    }
+    // Same-role messages merge when calls interleave, but no block is lost
+    blocks := 0
+    for _, msg := range client.GetConversation() {
+        blocks += len(msg.Content)
+    }
+    if blocks != 2*calls {
+        t.Errorf("conversation holds %d blocks, want %d", blocks, 2*calls)
+    }
+    if usage := client.SessionUsage(); usage.InputTokens != 10*calls {
+        t.Errorf("session input tokens = %d, want %d", usage.InputTokens, 10*calls)
+    }
+}
+
+func TestStopSequencesSerializedOnlyWhenSet(t *testing.T) {
+    srv := anthropictest.NewServer(
+        anthropictest.TextResponse("a"),
+        anthropictest.TextResponse("b"),
+        anthropictest.TextResponse("c"),
+    )
+    defer srv.Close()
+    var bodies []string
+    client := srv.Client(
+        goanthropic.WithRequestSigner(func(body []byte, headers http.Header) {
+            bodies = append(bodies, string(body))
+        }),
+    )
+
+    chatTurns(t, client, "plain")
+    if _, err := client.ChatMe(context.Background(), "stop", &types.MessageParams{StopSequences: []string{"\n\nHuman:"}}); err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    handlers := []types.ToolHandler{textTool("search", "")}
+    params := goanthropic.NewToolParams(handlers...)
+    params.StopSequences = []string{"END"}
+    if _, err := client.ChatWithTools(context.Background(), "tools", &params, handlers); err != nil {
+        t.Fatalf("ChatWithTools: %v", err)
+    }
+
+    if strings.Contains(bodies[0], "stop_sequences") {
+        t.Errorf("request without stop sequences sent %s", bodies[0])
+    }
+    if !strings.Contains(bodies[1], `"stop_sequences":["\n\nHuman:"]`) {
+        t.Errorf("ChatMe request = %s, want the stop sequence", bodies[1])
+    }
+    if !strings.Contains(bodies[2], `"stop_sequences":["END"]`) {
+        t.Errorf("ChatWithTools request = %s, want the stop sequence", bodies[2])
+    }
+}
+
+func TestEmptyResponseKeepsTurnsAlternating(t *testing.T) {
+    empty := anthropictest.TextResponse("")
+    empty.Content = nil
+    srv := anthropictest.NewServer(anthropictest.TextResponse("a"), empty, anthropictest.TextResponse("b"))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+
+    chatTurns(t, client, "one")
+    _, err := client.ChatMe(context.Background(), "two", nil)
+    if !errors.Is(err, goanthropic.ErrEmptyResponse) {
+        t.Fatalf("err = %v, want ErrEmptyResponse", err)
+    }
+    if conversation := client.GetConversation(); len(conversation) != 2 || conversation[1].Role != types.RoleAssistant {
+        t.Fatalf("conversation = %+v, want only the first exchange", conversation)
+    }
+
+    // The next message follows the assistant turn, not another user turn
+    chatTurns(t, client, "three")
+    req, _ := srv.LastRequest()
+    for i, msg := range req.Messages {
+        want := types.RoleUser
+        if i%2 == 1 {
+            want = types.RoleAssistant
+        }
+        if msg.Role != want {
+            t.Errorf("message %d role = %s, want %s", i, msg.Role, want)
+        }
+    }
+    if last := req.Messages[len(req.Messages)-1]; len(last.Content) != 1 || last.Content[0].Text != "three" {
+        t.Errorf("last message = %+v, want only the new question", last)
+    }
+}
@@ -0,0 +1,164 @@
+package goanthropic
+
+import (
+    "context"
+    "fmt"
+    "net/url"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// maxImageBase64Bytes is the largest base64 payload accepted for a single
+// image, matching the API's 5MB limit on the decoded image
+const maxImageBase64Bytes = (5*1024*1024 + 2) / 3 * 4
+
+// ChatWithImage sends text together with one or more images in a single user
+// message, for use with vision-capable models. Images are placed before the
+// text, which is the layout the API recommends. Images may be base64 data or
+// http(s) URLs, mixed freely within one message.
+func (c *AnthropicClient) ChatWithImage(ctx context.Context, text string, images []types.ImageSource, params *types.MessageParams) (*types.AnthropicResponse, error) {
+    if len(images) == 0 {
+        return nil, fmt.Errorf("at least one image is required")
+    }
+
+    content := make([]types.MessageContent, 0, len(images)+1)
+    for i, image := range images {
+        if err := validateImage(image); err != nil {
+            return nil, fmt.Errorf("image %d: %w", i, err)
+        }
+        source := image
+        content = append(content, types.MessageContent{
+            Type:   types.ContentTypeImage,
+            Source: &source,
+        })
+    }
+    if text != "" {
+        content = append(content, types.MessageContent{
+            Type: types.ContentTypeText,
+            Text: text,
+        })
+    }
+    return c.ChatMessage(ctx, content, params)
+}
+
+// validateImage checks that an image source can be sent to the API
+func validateImage(image types.ImageSource) error {
+    if image.Type == types.ImageSourceURL {
+        u, err := url.Parse(image.URL)
+        if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            return fmt.Errorf("invalid image URL %q: must be an absolute http or https URL", image.URL)
+        }
+        return nil
+    }
+    if image.Type != types.ImageSourceBase64 {
+        return fmt.Errorf("unsupported source type %q", image.Type)
+    }
+    switch image.MediaType {
+    case types.MediaTypePNG, types.MediaTypeJPEG, types.MediaTypeGIF, types.MediaTypeWebP:
+    default:
+        return fmt.Errorf("unsupported media type %q", image.MediaType)
+    }
+    if image.Data == "" {
+        return fmt.Errorf("image data is empty")
+    }
+    if len(image.Data) > maxImageBase64Bytes {
+        return fmt.Errorf("image data is %d bytes, limit is %d", len(image.Data), maxImageBase64Bytes)
+    }
+    return nil
+}
+
+// ImageLimitMode controls what happens when a message carries more images than
+// allowed by WithMaxImagesPerRequest
+type ImageLimitMode int
+
+const (
+    // ImageLimitReject fails the request before it is sent (the default)
+    ImageLimitReject ImageLimitMode = iota
+    // ImageLimitDrop removes the extra images, keeping the first ones, and
+    // reports a warning. The stored conversation is not modified.
+    ImageLimitDrop
+)
+
+// WithMaxImagesPerRequest limits how many image blocks a single message may
+// contain. It protects against accidental bulk attachments, which can exceed
+// API limits and are expensive.
+func WithMaxImagesPerRequest(n int) ClientOption {
+    return func(c *AnthropicClient) {
+        if n > 0 {
+            c.maxImages = n
+        }
+    }
+}
+
+// WithImageLimitMode sets whether messages over the image limit are rejected or trimmed
+func WithImageLimitMode(mode ImageLimitMode) ClientOption {
+    return func(c *AnthropicClient) {
+        c.imageLimit = mode
+    }
+}
+
+// limitImages applies the image limit to every message in the request. When
+// images are dropped the returned request holds copies of the affected
+// messages so the conversation history stays intact.
+func (c *AnthropicClient) limitImages(req types.Request) (types.Request, error) {
+    if c.maxImages <= 0 {
+        return req, nil
+    }
+
+    var messages []types.Message
+    for i, msg := range req.Messages {
+        count := countImages(msg.Content)
+        if count <= c.maxImages {
+            continue
+        }
+        if c.imageLimit != ImageLimitDrop {
+            return req, fmt.Errorf("message %d has %d images, limit is %d", i, count, c.maxImages)
+        }
+
+        if messages == nil {
+            messages = make([]types.Message, len(req.Messages))
+            copy(messages, req.Messages)
+        }
+        kept := make([]types.MessageContent, 0, len(msg.Content))
+        images := 0
+        for _, content := range msg.Content {
+            if content.Type == types.ContentTypeImage {
+                images++
+                if images > c.maxImages {
+                    continue
+                }
+            }
+            kept = append(kept, content)
+        }
+        messages[i].Content = kept
+        c.warn("Dropped %d of %d images from message %d (limit %d)", count-c.maxImages, count, i, c.maxImages)
+    }
+
+    if messages != nil {
+        req.Messages = messages
+    }
+    return req, nil
+}
+
+// checkImageLimit rejects content over the image limit before it is stored,
+// so a rejected message never becomes part of the conversation
+func (c *AnthropicClient) checkImageLimit(content []types.MessageContent) error {
+    if c.maxImages <= 0 || c.imageLimit == ImageLimitDrop {
+        return nil
+    }
+    if count := countImages(content); count > c.maxImages {
+        return fmt.Errorf("message has %d images, limit is %d", count, c.maxImages)
+    }
+    return nil
+}
+
+// countImages returns the number of image blocks in content
+func countImages(content []types.MessageContent) int {
+    count := 0
+    for _, block := range content {
+        if block.Type == types.ContentTypeImage {
+            count++
+        }
+    }
+    return count
+}
@@ -0,0 +1,125 @@
+package goanthropic_test
+
+import (
+    "context"
+    "net/http"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// pngImages returns n small base64 PNG sources
+func pngImages(n int) []types.ImageSource {
+    images := make([]types.ImageSource, n)
+    for i := range images {
+        images[i] = types.Base64Image(types.MediaTypePNG, "iVBORw0KGgo=")
+    }
+    return images
+}
+
+// countImages returns the number of image blocks in content
+func countImages(content []types.MessageContent) int {
+    n := 0
+    for _, block := range content {
+        if block.Type == types.ContentTypeImage {
+            n++
+        }
+    }
+    return n
+}
+
+func TestMaxImagesPerRequestRejects(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("Two cats"))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithMaxImagesPerRequest(2))
+
+    if _, err := client.ChatWithImage(context.Background(), "Compare", pngImages(2), nil); err != nil {
+        t.Fatalf("ChatWithImage with 2 images: %v", err)
+    }
+
+    _, err := client.ChatWithImage(context.Background(), "Compare", pngImages(3), nil)
+    if err == nil || !strings.Contains(err.Error(), "has 3 images, limit is 2") {
+        t.Fatalf("err = %v, want the image limit error", err)
+    }
+    if got := len(srv.Requests()); got != 1 {
+        t.Errorf("sent %d requests, want only the one within the limit", got)
+    }
+
+    // The rejected message is not stored, so a text-only follow-up succeeds
+    srv.Enqueue(anthropictest.TextResponse("Yes"))
+    chatTurns(t, client, "Are they the same cat?")
+    req, _ := srv.LastRequest()
+    if last := req.Messages[len(req.Messages)-1]; countImages(last.Content) != 0 || len(req.Messages) != 3 {
+        t.Errorf("follow-up sent %d messages ending with %+v", len(req.Messages), last)
+    }
+}
+
+func TestMaxImagesPerRequestDrops(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("Two cats"))
+    defer srv.Close()
+    warnings := &warningRecorder{}
+    client := srv.Client(
+        goanthropic.WithMaxImagesPerRequest(2),
+        goanthropic.WithImageLimitMode(goanthropic.ImageLimitDrop),
+        goanthropic.WithWarningHandler(warnings.handle),
+    )
+
+    if _, err := client.ChatWithImage(context.Background(), "Compare", pngImages(3), nil); err != nil {
+        t.Fatalf("ChatWithImage: %v", err)
+    }
+    req, _ := srv.LastRequest()
+    if got := countImages(req.Messages[0].Content); got != 2 {
+        t.Errorf("sent %d images, want 2", got)
+    }
+    if all := warnings.all(); len(all) != 1 || !strings.Contains(all[0], "Dropped 1 of 3 images") {
+        t.Errorf("warnings = %q", all)
+    }
+}
+
+func TestChatWithImageMixesURLAndBase64(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("Two cats"))
+    defer srv.Close()
+    var body string
+    client := srv.Client(
+        goanthropic.WithModel("claude-3-5-sonnet-20241022"),
+        goanthropic.WithRequestSigner(func(b []byte, headers http.Header) {
+            body = string(b)
+        }),
+    )
+
+    images := []types.ImageSource{
+        types.URLImage("https://cdn.example.com/cat.png"),
+        types.Base64Image(types.MediaTypePNG, "iVBORw0KGgo="),
+    }
+    if _, err := client.ChatWithImage(context.Background(), "Same cat?", images, nil); err != nil {
+        t.Fatalf("ChatWithImage: %v", err)
+    }
+
+    wantBlocks := []string{
+        `{"type":"image","source":{"type":"url","url":"https://cdn.example.com/cat.png"}}`,
+        `{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}`,
+        `{"type":"text","text":"Same cat?"}`,
+    }
+    if want := `"content":[` + strings.Join(wantBlocks, ",") + `]`; !strings.Contains(body, want) {
+        t.Errorf("request body = %s\nwant it to contain %s", body, want)
+    }
+}
+
+func TestChatWithImageRejectsBadURLs(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+
+    for _, url := range []string{"ftp://cdn.example.com/cat.png", "/images/cat.png", "javascript:alert(1)", "https://"} {
+        _, err := client.ChatWithImage(context.Background(), "Cat?", []types.ImageSource{types.URLImage(url)}, nil)
+        if err == nil || !strings.Contains(err.Error(), "invalid image URL") {
+            t.Errorf("%q: err = %v, want an invalid URL error", url, err)
+        }
+    }
+    if got := len(srv.Requests()); got != 0 {
+        t.Errorf("sent %d requests, want none", got)
+    }
+}
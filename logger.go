@@ -0,0 +1,71 @@
+package goanthropic
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+
+    "github.com/rdhillbb/logging"
+)
+
+// Logger receives the client's log output. Its methods match those of
+// *slog.Logger, so a slog logger can be passed directly; args are optional
+// alternating key/value pairs.
+type Logger interface {
+    Debug(msg string, args ...interface{})
+    Info(msg string, args ...interface{})
+    Error(msg string, args ...interface{})
+}
+
+// WithLogger sends the client's logs to logger instead of the global logging
+// package. Request traces and payloads are logged at debug level, warnings not
+// handled by WithWarningHandler at info level and failures at error level.
+func WithLogger(logger Logger) ClientOption {
+    return func(c *AnthropicClient) {
+        c.logger = logger
+    }
+}
+
+// logMessage writes a debug message to the client's logger, or to the global
+// logging package when none is set
+func (c *AnthropicClient) logMessage(format string, args ...interface{}) {
+    if c.logger == nil {
+        logMessage("%s", c.maskKey(fmt.Sprintf(format, args...)))
+        return
+    }
+    c.logger.Debug(c.maskKey(fmt.Sprintf(format, args...)))
+}
+
+// logError writes an error message to the client's logger, or to the global
+// logging package when none is set
+func (c *AnthropicClient) logError(format string, args ...interface{}) {
+    if c.logger == nil {
+        logMessage("%s", c.maskKey(fmt.Sprintf(format, args...)))
+        return
+    }
+    c.logger.Error(c.maskKey(fmt.Sprintf(format, args...)))
+}
+
+// logJSON writes data as indented JSON to the client's logger, or to the
+// global logging package when none is set. Fields chosen with
+// WithLogRedaction and the API key are masked.
+func (c *AnthropicClient) logJSON(prefix string, data interface{}) {
+    if c.logger == nil && !logging.IsLoggingEnabled() {
+        return
+    }
+    jsonBytes, err := c.redactedJSON(data)
+    if err != nil {
+        c.logError("%s: failed to marshal JSON: %v", prefix, err)
+        return
+    }
+    if c.logger == nil {
+        logJSON(prefix, json.RawMessage(jsonBytes))
+        return
+    }
+    var indented bytes.Buffer
+    if err := json.Indent(&indented, jsonBytes, "", "  "); err != nil {
+        c.logError("%s: failed to format JSON: %v", prefix, err)
+        return
+    }
+    c.logger.Debug(prefix, "json", indented.String())
+}
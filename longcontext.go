@@ -0,0 +1,43 @@
+package goanthropic
+
+import (
+    "fmt"
+    "strings"
+)
+
+// longContextBeta enables the 1M token context window
+const longContextBeta = "context-1m-2025-08-07"
+
+// longContextModels are the model name prefixes that accept longContextBeta
+var longContextModels = []string{
+    "claude-sonnet-4-20250514",
+    "claude-sonnet-4-0",
+    "claude-sonnet-4-5",
+}
+
+// WithLongContext enables the 1M token context window by sending the
+// context-1m beta header with every request. It is supported by Claude Sonnet
+// 4 and Claude Sonnet 4.5 (claude-sonnet-4-20250514, claude-sonnet-4-0 and
+// claude-sonnet-4-5 and their dated versions); requests for any other model
+// fail before they are sent. Input beyond 200K tokens is billed at a higher
+// rate.
+func WithLongContext() ClientOption {
+    return func(c *AnthropicClient) {
+        c.longContext = true
+        c.betaFeatures = appendUnique(c.betaFeatures, longContextBeta)
+    }
+}
+
+// validateLongContext rejects models that do not support the 1M context
+// window when it is enabled
+func (c *AnthropicClient) validateLongContext(model string) error {
+    if !c.longContext {
+        return nil
+    }
+    for _, prefix := range longContextModels {
+        if strings.HasPrefix(model, prefix) {
+            return nil
+        }
+    }
+    return fmt.Errorf("model %s does not support the 1M context window (supported: %s)", model, strings.Join(longContextModels, ", "))
+}
@@ -0,0 +1,184 @@
+package goanthropic
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/url"
+    "strings"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// modelsPageSize is the number of models requested per page
+const modelsPageSize = 100
+
+// modelPage is one page of the models list
+type modelPage struct {
+    Data    []types.Model `json:"data"`
+    HasMore bool          `json:"has_more"`
+    LastID  string        `json:"last_id"`
+}
+
+// WithModelListCache keeps the result of the first successful ListModels call
+// for the lifetime of the client, so later calls make no request
+func WithModelListCache() ClientOption {
+    return func(c *AnthropicClient) {
+        c.cacheModels = true
+    }
+}
+
+// ListModels returns the models available to the API key, most recently
+// released first, following pagination until the list is complete
+func (c *AnthropicClient) ListModels(ctx context.Context) ([]types.Model, error) {
+    c = c.root()
+    c.mu.Lock()
+    cached := c.models
+    c.mu.Unlock()
+    if cached != nil {
+        return append([]types.Model(nil), cached...), nil
+    }
+
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    var models []types.Model
+    afterID := ""
+    for {
+        query := url.Values{"limit": {fmt.Sprint(modelsPageSize)}}
+        if afterID != "" {
+            query.Set("after_id", afterID)
+        }
+        body, err := c.getJSON(ctx, c.endpoint(modelsPath)+"?"+query.Encode())
+        if err != nil {
+            c.recordError(err)
+            return nil, err
+        }
+
+        var page modelPage
+        if err := json.Unmarshal(body, &page); err != nil {
+            c.logError("Error parsing models response: %v", err)
+            return nil, fmt.Errorf("error parsing response: %w", err)
+        }
+        models = append(models, page.Data...)
+        if !page.HasMore || page.LastID == "" {
+            break
+        }
+        afterID = page.LastID
+    }
+
+    if c.cacheModels {
+        c.mu.Lock()
+        c.models = append([]types.Model{}, models...)
+        c.mu.Unlock()
+    }
+    return models, nil
+}
+
+// ErrUnknownModel is matched by errors.Is when model validation is enabled
+// and a request names a model that is not known
+var ErrUnknownModel = errors.New("unknown model")
+
+// WithModelValidation checks the model of every request before it is sent,
+// so a mistyped name fails with ErrUnknownModel and the list of valid models
+// instead of an API error. The models given are the allowed list; without
+// any, the list is fetched once with ListModels and kept for the lifetime of
+// the client. A model also passes when it is an alias of a listed model, such
+// as claude-sonnet-4-5 for claude-sonnet-4-5-20250929. If the list cannot be
+// fetched, requests are sent unchecked.
+func WithModelValidation(models ...string) ClientOption {
+    return func(c *AnthropicClient) {
+        c.validateModels = true
+        c.knownModels = appendUnique(c.knownModels, models...)
+    }
+}
+
+// validateModel checks model against the known models when validation is enabled
+func (c *AnthropicClient) validateModel(ctx context.Context, model string) error {
+    if !c.validateModels {
+        return nil
+    }
+    known, err := c.knownModelIDs(ctx)
+    if err != nil {
+        c.warn("Skipping model validation: %v", err)
+        return nil
+    }
+    for _, id := range known {
+        if modelMatches(model, id) {
+            return nil
+        }
+    }
+    return fmt.Errorf("%w %q (valid models: %s)", ErrUnknownModel, model, strings.Join(known, ", "))
+}
+
+// knownModelIDs returns the allowed models, fetching them on first use when
+// none were configured
+func (c *AnthropicClient) knownModelIDs(ctx context.Context) ([]string, error) {
+    root := c.root()
+    c.mu.Lock()
+    known := root.knownModels
+    c.mu.Unlock()
+    if len(known) > 0 {
+        return known, nil
+    }
+
+    models, err := c.ListModels(ctx)
+    if err != nil {
+        return nil, err
+    }
+    for _, model := range models {
+        known = append(known, model.ID)
+    }
+    if len(known) == 0 {
+        return nil, fmt.Errorf("no models were listed")
+    }
+    c.mu.Lock()
+    root.knownModels = known
+    c.mu.Unlock()
+    return known, nil
+}
+
+// modelMatches reports whether model names the model with the given ID,
+// either exactly or as one of its aliases
+func modelMatches(model, id string) bool {
+    for _, alias := range modelAliases(id) {
+        if model == alias {
+            return true
+        }
+    }
+    return false
+}
+
+// modelAliases returns the names accepted for the model with the given ID:
+// the ID itself, its undated form such as claude-3-7-sonnet, that form with
+// -latest, and for models numbered with a single major version, such as
+// claude-sonnet-4-20250514, the -0 form claude-sonnet-4-0
+func modelAliases(id string) []string {
+    aliases := []string{id}
+    base := id
+    if i := strings.LastIndex(id, "-"); i > 0 && isDigits(id[i+1:]) && len(id)-i-1 == 8 {
+        base = id[:i]
+        aliases = append(aliases, base)
+    }
+    aliases = append(aliases, base+"-latest")
+
+    parts := strings.Split(base, "-")
+    if n := len(parts); n >= 2 && isDigits(parts[n-1]) && !isDigits(parts[n-2]) {
+        aliases = append(aliases, base+"-0")
+    }
+    return aliases
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits
+func isDigits(s string) bool {
+    if s == "" {
+        return false
+    }
+    for _, r := range s {
+        if r < '0' || r > '9' {
+            return false
+        }
+    }
+    return true
+}
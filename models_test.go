@@ -0,0 +1,35 @@
+package goanthropic
+
+import "testing"
+
+func TestModelMatches(t *testing.T) {
+    tests := []struct {
+        model string
+        id    string
+        want  bool
+    }{
+        {"claude-sonnet-4-5-20250929", "claude-sonnet-4-5-20250929", true},
+        {"claude-sonnet-4-5", "claude-sonnet-4-5-20250929", true},
+        {"claude-sonnet-4-5-latest", "claude-sonnet-4-5-20250929", true},
+        {"claude-sonnet-4-0", "claude-sonnet-4-20250514", true},
+        {"claude-sonnet-4", "claude-sonnet-4-20250514", true},
+        {"claude-3-7-sonnet-latest", "claude-3-7-sonnet-20250219", true},
+        {"claude-3-7-sonnet", "claude-3-7-sonnet-20250219", true},
+
+        // Prefixes of an ID are not aliases
+        {"claude", "claude-sonnet-4-5-20250929", false},
+        {"claude-3", "claude-3-7-sonnet-20250219", false},
+        {"claude-sonnet", "claude-sonnet-4-5-20250929", false},
+        {"claude-sonnet-4", "claude-sonnet-4-5-20250929", false},
+        // -0 names the first release of a major version only
+        {"claude-sonnet-4-0", "claude-sonnet-4-5-20250929", false},
+        {"claude-sonnet-4-5-0", "claude-sonnet-4-5-20250929", false},
+        {"claude-3-7-sonnet-0", "claude-3-7-sonnet-20250219", false},
+        {"claude-sonnet-4-5-2025", "claude-sonnet-4-5-20250929", false},
+    }
+    for _, tt := range tests {
+        if got := modelMatches(tt.model, tt.id); got != tt.want {
+            t.Errorf("modelMatches(%q, %q) = %v, want %v", tt.model, tt.id, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,39 @@
+package goanthropic
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// pingMessage is the single word counted by Ping
+const pingMessage = "ping"
+
+// Ping checks the API key and connectivity by counting the tokens of a
+// one-word message, which costs nothing and generates no output. It returns
+// nil on success; use IsAuthError to recognise a rejected key, while network
+// failures are returned as they occurred. Without a configured model the
+// tokens are counted for the library's default model. The conversation is not
+// used.
+func (c *AnthropicClient) Ping(ctx context.Context) error {
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    model := c.mergeParams(nil).Model
+    if model == "" {
+        model = defaultModel
+    }
+    _, err := c.countTokens(ctx, types.CountTokensRequest{
+        Model: model,
+        Messages: []types.Message{{
+            Role:    types.RoleUser,
+            Content: []types.MessageContent{{Type: types.ContentTypeText, Text: pingMessage}},
+        }},
+    })
+    if err != nil {
+        c.recordError(err)
+        return fmt.Errorf("ping failed: %w", err)
+    }
+    return nil
+}
@@ -0,0 +1,53 @@
+package goanthropic
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// validatePrefill checks that an assistant prefill can be sent with params
+func validatePrefill(params types.MessageParams) error {
+    if params.Prefill == "" {
+        return nil
+    }
+    if strings.TrimRight(params.Prefill, " \t\r\n") != params.Prefill {
+        return fmt.Errorf("prefill cannot end with whitespace")
+    }
+    if params.Thinking != nil {
+        return fmt.Errorf("prefill cannot be combined with extended thinking")
+    }
+    return nil
+}
+
+// appendPrefill adds the prefill as the final assistant message of a request
+func appendPrefill(messages []types.Message, prefill string) []types.Message {
+    if prefill == "" {
+        return messages
+    }
+    return append(messages, types.Message{
+        Role: types.RoleAssistant,
+        Content: []types.MessageContent{{
+            Type: types.ContentTypeText,
+            Text: prefill,
+        }},
+    })
+}
+
+// withPrefill joins the prefill to the reply the model continued from it, so
+// the stored assistant turn reads as one complete answer
+func withPrefill(content []types.MessageContent, prefill string) []types.MessageContent {
+    if prefill == "" {
+        return content
+    }
+    if len(content) > 0 && content[0].Type == types.ContentTypeText {
+        joined := append([]types.MessageContent(nil), content...)
+        joined[0].Text = prefill + joined[0].Text
+        return joined
+    }
+    return append([]types.MessageContent{{
+        Type: types.ContentTypeText,
+        Text: prefill,
+    }}, content...)
+}
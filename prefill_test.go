@@ -0,0 +1,47 @@
+package goanthropic_test
+
+import (
+    "context"
+    "net/http"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// lastText returns the text of the first block of the final message
+func lastText(messages []types.Message) string {
+    if len(messages) == 0 || len(messages[len(messages)-1].Content) == 0 {
+        return ""
+    }
+    return messages[len(messages)-1].Content[0].Text
+}
+
+func TestPrefillSurvivesRequestTooLargeRetry(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("a"), anthropictest.TextResponse("b"))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"), goanthropic.WithCompactOnRequestTooLarge())
+    chatTurns(t, client, "one", "two")
+
+    srv.EnqueueError(http.StatusRequestEntityTooLarge, "request_too_large", "too big")
+    srv.Enqueue(anthropictest.TextResponse(`"ok": true}`))
+    content := []types.MessageContent{{Type: types.ContentTypeText, Text: "Reply in JSON"}}
+    if _, err := client.ChatMessage(context.Background(), content, &types.MessageParams{Prefill: "{"}); err != nil {
+        t.Fatalf("ChatMessage: %v", err)
+    }
+
+    reqs := srv.Requests()
+    for _, req := range reqs[len(reqs)-2:] {
+        last := req.Messages[len(req.Messages)-1]
+        if last.Role != types.RoleAssistant || lastText(req.Messages) != "{" {
+            t.Errorf("request ends with %s %q, want the assistant prefill", last.Role, lastText(req.Messages))
+        }
+    }
+    if len(reqs[len(reqs)-1].Messages) >= len(reqs[len(reqs)-2].Messages) {
+        t.Error("retry was not compacted")
+    }
+    if got := lastText(client.GetConversation()); got != `{"ok": true}` {
+        t.Errorf("stored reply = %q, want the prefill joined to the response", got)
+    }
+}
@@ -0,0 +1,58 @@
+package goanthropic
+
+import (
+    "fmt"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// ModelPricing holds a model's prices in US dollars per million tokens
+type ModelPricing struct {
+    Input      float64
+    Output     float64
+    CacheWrite float64
+    CacheRead  float64
+}
+
+// defaultPricing is the built-in price table. Prices change over time; use
+// WithPricing to correct them or to add new models.
+var defaultPricing = map[string]ModelPricing{
+    "claude-opus-4-20250514":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+    "claude-sonnet-4-20250514":   {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+    "claude-3-7-sonnet-20250219": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+    "claude-3-5-sonnet-20241022": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+    "claude-3-5-sonnet-20240620": {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.30},
+    "claude-3-5-haiku-20241022":  {Input: 0.80, Output: 4, CacheWrite: 1, CacheRead: 0.08},
+    "claude-3-opus-20240229":     {Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.50},
+    "claude-3-haiku-20240307":    {Input: 0.25, Output: 1.25, CacheWrite: 0.30, CacheRead: 0.03},
+}
+
+// WithPricing adds or replaces entries in the price table used by EstimateCost
+func WithPricing(pricing map[string]ModelPricing) ClientOption {
+    return func(c *AnthropicClient) {
+        if c.pricing == nil {
+            c.pricing = make(map[string]ModelPricing, len(pricing))
+        }
+        for model, price := range pricing {
+            c.pricing[model] = price
+        }
+    }
+}
+
+// EstimateCost returns the dollar cost of usage on the given model, including
+// cache writes and reads. It returns an error when the model has no price.
+func (c *AnthropicClient) EstimateCost(usage types.Usage, model string) (float64, error) {
+    price, ok := c.pricing[model]
+    if !ok {
+        price, ok = defaultPricing[model]
+    }
+    if !ok {
+        return 0, fmt.Errorf("no pricing for model %q; add it with WithPricing", model)
+    }
+
+    cost := float64(usage.InputTokens)*price.Input +
+        float64(usage.OutputTokens)*price.Output +
+        float64(usage.CacheCreationInputTokens)*price.CacheWrite +
+        float64(usage.CacheReadInputTokens)*price.CacheRead
+    return cost / 1e6, nil
+}
@@ -0,0 +1,78 @@
+package goanthropic
+
+import (
+    "encoding/json"
+    "fmt"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// maxPromptLogBlob is the largest text or input captured verbatim by prompt logging
+const maxPromptLogBlob = 4096
+
+// WithPromptLogging sends every fully assembled prompt (system prompt,
+// messages and tools) to sink before it is sent. This is independent of debug
+// logging and is intended for prompt regression tracking and audits. Large
+// text, tool inputs, image and document data and opaque payloads are
+// replaced with a size marker.
+func WithPromptLogging(sink func(types.PromptRecord)) ClientOption {
+    return func(c *AnthropicClient) {
+        c.promptSink = sink
+    }
+}
+
+// logPrompt passes a masked copy of the request to the prompt sink
+func (c *AnthropicClient) logPrompt(req types.Request) {
+    if c.promptSink == nil {
+        return
+    }
+
+    messages := make([]types.Message, len(req.Messages))
+    for i, msg := range req.Messages {
+        messages[i] = msg
+        messages[i].Content = maskBlobs(msg.Content)
+    }
+
+    c.promptSink(types.PromptRecord{
+        Timestamp: c.now(),
+        Model:     req.Model,
+        System:    req.System,
+        Messages:  messages,
+        Tools:     req.Tools,
+    })
+}
+
+// maskBlobs returns a copy of content with large payloads replaced by size markers
+func maskBlobs(content []types.MessageContent) []types.MessageContent {
+    masked := make([]types.MessageContent, len(content))
+    for i, block := range content {
+        if len(block.Text) > maxPromptLogBlob {
+            block.Text = blobMarker(len(block.Text))
+        }
+        if len(block.Content) > maxPromptLogBlob {
+            block.Content = blobMarker(len(block.Content))
+        }
+        if len(block.Input) > maxPromptLogBlob {
+            block.Input = json.RawMessage(fmt.Sprintf("%q", blobMarker(len(block.Input))))
+        }
+        if block.Data != "" {
+            block.Data = blobMarker(len(block.Data))
+        }
+        if block.Source != nil && block.Source.Data != "" {
+            // Copy the source so the stored conversation keeps its data
+            source := *block.Source
+            source.Data = blobMarker(len(source.Data))
+            block.Source = &source
+        }
+        if block.ContentBlocks != nil {
+            block.ContentBlocks = maskBlobs(block.ContentBlocks)
+        }
+        masked[i] = block
+    }
+    return masked
+}
+
+// blobMarker describes an omitted payload
+func blobMarker(size int) string {
+    return fmt.Sprintf("[%d bytes omitted]", size)
+}
@@ -0,0 +1,100 @@
+package goanthropic_test
+
+import (
+    "context"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestPromptLoggingReceivesAssembledPrompt(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("Summarized"), anthropictest.TextResponse("Searched"))
+    defer srv.Close()
+    var records []types.PromptRecord
+    client := srv.Client(
+        goanthropic.WithSystemPrompt("Summarize reports briefly."),
+        goanthropic.WithPromptLogging(func(record types.PromptRecord) {
+            records = append(records, record)
+        }),
+    )
+
+    document := strings.Repeat("A long report. ", 500)
+    if _, err := client.ChatMe(context.Background(), document, nil); err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+
+    if len(records) != 1 {
+        t.Fatalf("sink received %d records, want 1", len(records))
+    }
+    record := records[0]
+    if record.System != "Summarize reports briefly." {
+        t.Errorf("record system = %q", record.System)
+    }
+    if len(record.Messages) != 1 || len(record.Messages[0].Content) != 1 {
+        t.Fatalf("record messages = %+v", record.Messages)
+    }
+    if logged := record.Messages[0].Content[0].Text; logged != "[7500 bytes omitted]" {
+        t.Errorf("logged text = %.40q, want a size marker", logged)
+    }
+
+    // Masking must not touch what is sent
+    req, _ := srv.LastRequest()
+    if sent := req.Messages[0].Content[0].Text; sent != document {
+        t.Errorf("sent text changed to %.40q", sent)
+    }
+
+    // Tool calls log the tools they offer
+    handlers := []types.ToolHandler{textTool("search", "")}
+    params := goanthropic.NewToolParams(handlers...)
+    if _, err := client.ChatWithTools(context.Background(), "Search", &params, handlers); err != nil {
+        t.Fatalf("ChatWithTools: %v", err)
+    }
+    if len(records) != 2 || len(records[1].Tools) != 1 || records[1].Tools[0].Name != "search" {
+        t.Errorf("tool call logged %+v", records[len(records)-1].Tools)
+    }
+}
+
+func TestPromptLoggingMasksImages(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("A cat"))
+    defer srv.Close()
+    var records []types.PromptRecord
+    client := srv.Client(goanthropic.WithPromptLogging(func(record types.PromptRecord) {
+        records = append(records, record)
+    }))
+
+    data := strings.Repeat("iVBORw0K", 1000)
+    image := types.Base64Image(types.MediaTypePNG, data)
+    if _, err := client.ChatWithImage(context.Background(), "What is this?", []types.ImageSource{image}, nil); err != nil {
+        t.Fatalf("ChatWithImage: %v", err)
+    }
+
+    if len(records) != 1 || len(records[0].Messages) != 1 {
+        t.Fatalf("sink received %+v, want one record with one message", records)
+    }
+    var text, logged string
+    for _, block := range records[0].Messages[0].Content {
+        switch block.Type {
+        case types.ContentTypeText:
+            text = block.Text
+        case types.ContentTypeImage:
+            logged = block.Source.Data
+        }
+    }
+    if text != "What is this?" {
+        t.Errorf("logged text = %q", text)
+    }
+    if logged != "[8000 bytes omitted]" {
+        t.Errorf("logged image data = %.40q, want a size marker", logged)
+    }
+
+    // Masking must not touch what is sent
+    req, _ := srv.LastRequest()
+    for _, block := range req.Messages[0].Content {
+        if block.Type == types.ContentTypeImage && block.Source.Data != data {
+            t.Errorf("image data changed to %.40q", block.Source.Data)
+        }
+    }
+}
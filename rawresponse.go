@@ -0,0 +1,56 @@
+package goanthropic
+
+import (
+    "net/http"
+)
+
+// RawResponse is the HTTP response to an API request, as received
+type RawResponse struct {
+    StatusCode int
+    Header     http.Header
+    Body       []byte
+}
+
+// RequestID returns the request-id header, which Anthropic support asks for
+// when investigating a failed request
+func (r *RawResponse) RequestID() string {
+    return r.Header.Get("request-id")
+}
+
+// WithRawResponseCapture keeps the status, headers and body of the most recent
+// API response for LastResponse. It is off by default so that large response
+// bodies are not retained.
+func WithRawResponseCapture() ClientOption {
+    return func(c *AnthropicClient) {
+        c.captureResponses = true
+    }
+}
+
+// LastResponse returns the most recent non-streaming API response, successful
+// or not, or nil if capturing is disabled or no response has been received
+func (c *AnthropicClient) LastResponse() *RawResponse {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.lastResponse == nil {
+        return nil
+    }
+    raw := *c.lastResponse
+    raw.Header = raw.Header.Clone()
+    raw.Body = append([]byte(nil), raw.Body...)
+    return &raw
+}
+
+// recordResponse stores a response for LastResponse when capturing is enabled
+func (c *AnthropicClient) recordResponse(resp *http.Response, body []byte) {
+    if !c.captureResponses {
+        return
+    }
+    c = c.root()
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.lastResponse = &RawResponse{
+        StatusCode: resp.StatusCode,
+        Header:     resp.Header.Clone(),
+        Body:       body,
+    }
+}
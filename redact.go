@@ -0,0 +1,74 @@
+package goanthropic
+
+import (
+    "bytes"
+    "encoding/json"
+    "strings"
+)
+
+// redactedValue replaces masked data in logs
+const redactedValue = "[REDACTED]"
+
+// WithLogRedaction masks the named JSON fields wherever they appear in logged
+// payloads, for example "text", "content" and "input" to keep message content
+// out of debug logs. The API key is always masked, with or without this option.
+func WithLogRedaction(fields ...string) ClientOption {
+    return func(c *AnthropicClient) {
+        if c.redactFields == nil {
+            c.redactFields = make(map[string]bool)
+        }
+        for _, field := range fields {
+            c.redactFields[field] = true
+        }
+    }
+}
+
+// maskKey removes the API key from text about to be logged
+func (c *AnthropicClient) maskKey(text string) string {
+    if c.apiKey == "" {
+        return text
+    }
+    return strings.ReplaceAll(text, c.apiKey, redactedValue)
+}
+
+// redactedJSON encodes data for logging with the configured fields and the API key masked
+func (c *AnthropicClient) redactedJSON(data interface{}) ([]byte, error) {
+    jsonBytes, err := json.Marshal(data)
+    if err != nil {
+        return nil, err
+    }
+    if len(c.redactFields) > 0 {
+        decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+        decoder.UseNumber()
+        var value interface{}
+        if err := decoder.Decode(&value); err != nil {
+            return nil, err
+        }
+        if jsonBytes, err = json.Marshal(c.redactValue(value)); err != nil {
+            return nil, err
+        }
+    }
+    if c.apiKey != "" {
+        jsonBytes = bytes.ReplaceAll(jsonBytes, []byte(c.apiKey), []byte(redactedValue))
+    }
+    return jsonBytes, nil
+}
+
+// redactValue masks the configured fields in a decoded JSON value
+func (c *AnthropicClient) redactValue(value interface{}) interface{} {
+    switch v := value.(type) {
+    case map[string]interface{}:
+        for key, field := range v {
+            if c.redactFields[key] {
+                v[key] = redactedValue
+            } else {
+                v[key] = c.redactValue(field)
+            }
+        }
+    case []interface{}:
+        for i, item := range v {
+            v[i] = c.redactValue(item)
+        }
+    }
+    return value
+}
@@ -0,0 +1,168 @@
+package goanthropic
+
+import (
+    "fmt"
+    "strings"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// WithResponseValidator sets a check that every successful response must pass.
+// A response that fails validation is returned as an error unless retries are
+// configured with WithValidatorRetries.
+func WithResponseValidator(validator func(*types.AnthropicResponse) error) ClientOption {
+    return func(c *AnthropicClient) {
+        c.responseValidator = validator
+    }
+}
+
+// WithValidatorRetries sets how many times the model is asked to correct a
+// response that failed validation before the error is returned
+func WithValidatorRetries(retries int) ClientOption {
+    return func(c *AnthropicClient) {
+        if retries >= 0 {
+            c.validatorRetries = retries
+        }
+    }
+}
+
+// minResponseNudge asks the model to expand on a response that was too short
+const minResponseNudge = "Your previous response was incomplete. Please provide a complete answer."
+
+// WithMinResponseTokens retries once with a nudge prompt when a response ends
+// its turn having produced fewer than minTokens output tokens
+func WithMinResponseTokens(minTokens int) ClientOption {
+    return func(c *AnthropicClient) {
+        if minTokens > 0 {
+            c.minResponseTokens = minTokens
+        }
+    }
+}
+
+// WhitespaceMode controls what happens to assistant text blocks that contain only whitespace
+type WhitespaceMode int
+
+const (
+    // WhitespaceTrim drops whitespace-only text blocks before the response is stored (the default)
+    WhitespaceTrim WhitespaceMode = iota
+    // WhitespaceKeep stores responses exactly as received
+    WhitespaceKeep
+    // WhitespaceRetry drops whitespace-only blocks and, when nothing else was
+    // returned, retries once with the same nudge as WithMinResponseTokens
+    WhitespaceRetry
+)
+
+// WithWhitespaceResponseHandling sets how whitespace-only assistant text is handled.
+// Blank turns waste context and can confuse follow-up requests.
+func WithWhitespaceResponseHandling(mode WhitespaceMode) ClientOption {
+    return func(c *AnthropicClient) {
+        c.whitespaceMode = mode
+    }
+}
+
+// assistantContent returns the blocks of a response that should be stored in the conversation
+func (c *AnthropicClient) assistantContent(content []types.MessageContent) []types.MessageContent {
+    if c.whitespaceMode == WhitespaceKeep {
+        return content
+    }
+
+    kept := make([]types.MessageContent, 0, len(content))
+    for _, block := range content {
+        if isBlankText(block) {
+            c.logMessage("Dropping whitespace-only text block from assistant response")
+            continue
+        }
+        kept = append(kept, block)
+    }
+    return kept
+}
+
+// isBlankText reports whether block is a text block containing only whitespace
+func isBlankText(block types.MessageContent) bool {
+    return block.Type == types.ContentTypeText && strings.TrimSpace(block.Text) == ""
+}
+
+// needsLongerResponse reports whether a finished response fell short of the
+// minimum output or, in WhitespaceRetry mode, contained nothing but whitespace
+func (c *AnthropicClient) needsLongerResponse(response *types.AnthropicResponse) bool {
+    if response.StopReason != types.StopReasonEndTurn {
+        return false
+    }
+    if c.minResponseTokens > 0 && response.Usage.OutputTokens < c.minResponseTokens {
+        return true
+    }
+    if c.whitespaceMode != WhitespaceRetry {
+        return false
+    }
+    for _, block := range response.Content {
+        if !isBlankText(block) {
+            return false
+        }
+    }
+    return true
+}
+
+// checkResponse runs the configured response validator, if any. Refusals are
+// returned to the caller as they are; asking again would not change them.
+func (c *AnthropicClient) checkResponse(response *types.AnthropicResponse) error {
+    if c.responseValidator == nil || response.IsRefusal() {
+        return nil
+    }
+    if err := c.responseValidator(response); err != nil {
+        c.logMessage("Response failed validation: %v", err)
+        return fmt.Errorf("response validation failed: %w", err)
+    }
+    return nil
+}
+
+// validateResponse checks a response and, while retries remain, asks the model
+// to correct it by sending a follow-up prompt through resend. A response that
+// is shorter than the configured minimum is first retried once.
+func (c *AnthropicClient) validateResponse(response *types.AnthropicResponse, limit int, resend func() (*types.AnthropicResponse, error)) (*types.AnthropicResponse, error) {
+    // Under-generation gets a single nudge; it is never retried twice
+    if c.needsLongerResponse(response) {
+        c.logMessage("Response too short (%d output tokens), retrying", response.Usage.OutputTokens)
+        c.appendUserText(minResponseNudge)
+        c.trimConversationHistory(limit)
+
+        var err error
+        response, err = resend()
+        if err != nil {
+            return nil, err
+        }
+    }
+
+    for attempt := 0; ; attempt++ {
+        verr := c.checkResponse(response)
+        if verr == nil {
+            return response, nil
+        }
+        if attempt >= c.validatorRetries {
+            return nil, verr
+        }
+
+        c.addCorrectionPrompt(verr)
+        c.trimConversationHistory(limit)
+
+        var err error
+        response, err = resend()
+        if err != nil {
+            return nil, err
+        }
+    }
+}
+
+// addCorrectionPrompt tells the model why its previous response was rejected
+func (c *AnthropicClient) addCorrectionPrompt(reason error) {
+    c.appendUserText(fmt.Sprintf(
+        "Your previous response was rejected (%v). Please respond again, correcting this problem.", reason))
+}
+
+// appendUserText adds a text block from the user, extending the last message
+// when it is already a user turn so that roles keep alternating
+func (c *AnthropicClient) appendUserText(text string) {
+    c.addMessageToConversation(types.RoleUser, []types.MessageContent{{
+        Type: types.ContentTypeText,
+        Text: text,
+    }})
+}
@@ -0,0 +1,166 @@
+package goanthropic_test
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestResponseValidatorRetry(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("maybe"), anthropictest.TextResponse("yes"))
+    defer srv.Close()
+    validations := 0
+    client := srv.Client(
+        goanthropic.WithValidatorRetries(1),
+        goanthropic.WithResponseValidator(func(resp *types.AnthropicResponse) error {
+            validations++
+            if resp.Text() != "yes" && resp.Text() != "no" {
+                return errors.New("answer yes or no")
+            }
+            return nil
+        }),
+    )
+
+    resp, err := client.ChatMe(context.Background(), "Is it raining?", nil)
+    if err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if resp.Text() != "yes" || validations != 2 {
+        t.Errorf("reply = %q after %d validations, want %q after 2", resp.Text(), validations, "yes")
+    }
+    req, _ := srv.LastRequest()
+    if correction := req.Messages[len(req.Messages)-1].Content; !strings.Contains(correction[len(correction)-1].Text, "answer yes or no") {
+        t.Errorf("retry does not explain the rejection: %+v", correction)
+    }
+}
+
+func TestResponseValidatorWithoutRetries(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("maybe"))
+    defer srv.Close()
+    client := srv.Client(
+        goanthropic.WithResponseValidator(func(resp *types.AnthropicResponse) error {
+            return errors.New("never valid")
+        }),
+    )
+
+    if _, err := client.ChatMe(context.Background(), "Hi", nil); err == nil || !strings.Contains(err.Error(), "never valid") {
+        t.Fatalf("err = %v, want the validation error", err)
+    }
+    if got := len(srv.Requests()); got != 1 {
+        t.Errorf("sent %d requests, want 1", got)
+    }
+}
+
+// shortResponse returns a text response that reports outputTokens output tokens
+func shortResponse(text string, outputTokens int) types.AnthropicResponse {
+    resp := anthropictest.TextResponse(text)
+    resp.Usage.OutputTokens = outputTokens
+    return resp
+}
+
+func TestMinResponseTokensRetry(t *testing.T) {
+    srv := anthropictest.NewServer(shortResponse("OK", 1), shortResponse("A complete answer.", 40))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithMinResponseTokens(20))
+
+    resp, err := client.ChatMe(context.Background(), "Explain goroutines", nil)
+    if err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if resp.Text() != "A complete answer." {
+        t.Errorf("reply = %q, want the retried answer", resp.Text())
+    }
+    requests := srv.Requests()
+    if len(requests) != 2 {
+        t.Fatalf("sent %d requests, want 2", len(requests))
+    }
+    nudge := requests[1].Messages[len(requests[1].Messages)-1]
+    if nudge.Role != types.RoleUser || !strings.Contains(nudge.Content[len(nudge.Content)-1].Text, "incomplete") {
+        t.Errorf("retry does not end with the nudge: %+v", nudge)
+    }
+}
+
+func TestMinResponseTokensRetriesOnce(t *testing.T) {
+    srv := anthropictest.NewServer(shortResponse("OK", 1), shortResponse("Still short", 2))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithMinResponseTokens(20))
+
+    resp, err := client.ChatMe(context.Background(), "Explain goroutines", nil)
+    if err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if resp.Text() != "Still short" {
+        t.Errorf("reply = %q, want the second response", resp.Text())
+    }
+    if got := len(srv.Requests()); got != 2 {
+        t.Errorf("sent %d requests, want 2", got)
+    }
+}
+
+func TestWhitespaceResponseHandling(t *testing.T) {
+    tests := []struct {
+        name     string
+        mode     goanthropic.WhitespaceMode
+        requests int
+        reply    string
+    }{
+        {"keep", goanthropic.WhitespaceKeep, 1, " \n\t"},
+        {"retry", goanthropic.WhitespaceRetry, 2, "Hello there"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := anthropictest.NewServer(anthropictest.TextResponse(" \n\t"), anthropictest.TextResponse("Hello there"))
+            defer srv.Close()
+            client := srv.Client(goanthropic.WithWhitespaceResponseHandling(tt.mode))
+
+            resp, err := client.ChatMe(context.Background(), "Hi", nil)
+            if err != nil {
+                t.Fatalf("ChatMe: %v", err)
+            }
+            if resp.Text() != tt.reply {
+                t.Errorf("reply = %q, want %q", resp.Text(), tt.reply)
+            }
+            if got := len(srv.Requests()); got != tt.requests {
+                t.Errorf("sent %d requests, want %d", got, tt.requests)
+            }
+
+            conversation := client.GetConversation()
+            last := conversation[len(conversation)-1]
+            if last.Role != types.RoleAssistant || last.Content[0].Text != tt.reply {
+                t.Errorf("last stored message = %+v, want the assistant reply", last)
+            }
+        })
+    }
+}
+
+func TestWhitespaceBlockDropped(t *testing.T) {
+    response := anthropictest.TextResponse("Hello")
+    response.Content = append(response.Content, types.MessageContent{Type: types.ContentTypeText, Text: "\n\n"})
+    srv := anthropictest.NewServer(response)
+    defer srv.Close()
+    client := srv.Client()
+
+    chatTurns(t, client, "Hi")
+    conversation := client.GetConversation()
+    if last := conversation[len(conversation)-1]; len(last.Content) != 1 || last.Content[0].Text != "Hello" {
+        t.Errorf("stored reply = %+v, want the whitespace block dropped", last.Content)
+    }
+}
+
+func TestWhitespaceResponseTrimmed(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse(" \n\t"))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+
+    if _, err := client.ChatMe(context.Background(), "Hi", nil); !errors.Is(err, goanthropic.ErrEmptyResponse) {
+        t.Fatalf("err = %v, want ErrEmptyResponse", err)
+    }
+    if conversation := client.GetConversation(); len(conversation) != 0 {
+        t.Errorf("conversation = %+v, want the unanswered turn removed", conversation)
+    }
+}
@@ -0,0 +1,161 @@
+package goanthropic
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "math"
+    "sort"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// ValidateToolInput checks a tool input against the tool's InputSchema. It
+// verifies that required fields are present, that each value matches its
+// declared type, and that enum fields hold one of the allowed values. Nested
+// objects and array items are checked against their own properties in the same
+// way. Fields not described by the schema are accepted.
+func ValidateToolInput(tool types.Tool, input json.RawMessage) error {
+    var fields map[string]interface{}
+    decoder := json.NewDecoder(bytes.NewReader(input))
+    decoder.UseNumber()
+    if err := decoder.Decode(&fields); err != nil {
+        return fmt.Errorf("tool %s: input is not a JSON object: %w", tool.Name, err)
+    }
+    if fields == nil {
+        return fmt.Errorf("tool %s: input is not a JSON object", tool.Name)
+    }
+
+    if err := validateFields(tool.InputSchema.Properties, tool.InputSchema.Required, fields); err != nil {
+        return fmt.Errorf("tool %s: %w", tool.Name, err)
+    }
+    return nil
+}
+
+// validateFields checks the fields of a decoded JSON object against the
+// properties and required fields of its schema
+func validateFields(properties map[string]types.Property, required []string, fields map[string]interface{}) error {
+    for _, name := range required {
+        if _, ok := fields[name]; !ok {
+            return fmt.Errorf("missing required field %q", name)
+        }
+    }
+
+    // Check fields in a fixed order so the reported error is deterministic
+    names := make([]string, 0, len(fields))
+    for name := range fields {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    for _, name := range names {
+        prop, ok := properties[name]
+        if !ok {
+            continue
+        }
+        if err := validateProperty(prop, fields[name]); err != nil {
+            return fmt.Errorf("field %q: %w", name, err)
+        }
+    }
+    return nil
+}
+
+// validateProperty checks a single decoded JSON value against its property definition
+func validateProperty(prop types.Property, value interface{}) error {
+    if err := checkType(prop.Type, value); err != nil {
+        return err
+    }
+
+    if len(prop.Enum) > 0 && !isEnumValue(prop.Enum, value) {
+        return fmt.Errorf("value %q is not one of %v", fmt.Sprint(value), prop.Enum)
+    }
+
+    switch value := value.(type) {
+    case map[string]interface{}:
+        return validateFields(prop.Properties, prop.Required, value)
+    case []interface{}:
+        if prop.Items == nil {
+            return nil
+        }
+        for i, item := range value {
+            if err := validateProperty(*prop.Items, item); err != nil {
+                return fmt.Errorf("item %d: %w", i, err)
+            }
+        }
+    }
+    return nil
+}
+
+// isEnumValue reports whether value is one of the allowed enum values
+func isEnumValue(enum []string, value interface{}) bool {
+    actual := fmt.Sprint(value)
+    for _, allowed := range enum {
+        if actual == allowed {
+            return true
+        }
+    }
+    return false
+}
+
+// checkType verifies that value has the given JSON schema type
+func checkType(schemaType string, value interface{}) error {
+    valid := true
+    switch schemaType {
+    case "":
+        return nil
+    case "string":
+        _, valid = value.(string)
+    case "number":
+        _, valid = value.(json.Number)
+    case "integer":
+        valid = isInteger(value)
+    case "boolean":
+        _, valid = value.(bool)
+    case "array":
+        _, valid = value.([]interface{})
+    case "object":
+        _, valid = value.(map[string]interface{})
+    case "null":
+        valid = value == nil
+    default:
+        return fmt.Errorf("unsupported schema type %q", schemaType)
+    }
+
+    if !valid {
+        return fmt.Errorf("expected %s, got %s", schemaType, jsonTypeName(value))
+    }
+    return nil
+}
+
+// isInteger reports whether value is a JSON number without a fractional part
+func isInteger(value interface{}) bool {
+    number, ok := value.(json.Number)
+    if !ok {
+        return false
+    }
+    if _, err := number.Int64(); err == nil {
+        return true
+    }
+    f, err := number.Float64()
+    return err == nil && f == math.Trunc(f)
+}
+
+// jsonTypeName describes the JSON type of a decoded value for error messages
+func jsonTypeName(value interface{}) string {
+    switch value.(type) {
+    case nil:
+        return "null"
+    case string:
+        return "string"
+    case json.Number:
+        return "number"
+    case bool:
+        return "boolean"
+    case []interface{}:
+        return "array"
+    case map[string]interface{}:
+        return "object"
+    default:
+        return fmt.Sprintf("%T", value)
+    }
+}
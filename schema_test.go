@@ -0,0 +1,158 @@
+package goanthropic_test
+
+import (
+    "encoding/json"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+type address struct {
+    Street string `json:"street"`
+    City   string `json:"city"`
+}
+
+type lineItem struct {
+    SKU      string `json:"sku"`
+    Quantity int    `json:"quantity"`
+}
+
+type order struct {
+    Customer string     `json:"customer"`
+    Ship     address    `json:"ship"`
+    Items    []lineItem `json:"items"`
+    Status   string     `json:"status" enum:"open,closed"`
+}
+
+// orderTool returns a tool whose schema is built from order
+func orderTool(t *testing.T) types.Tool {
+    t.Helper()
+    schema, err := goanthropic.SchemaFor(order{})
+    if err != nil {
+        t.Fatalf("SchemaFor: %v", err)
+    }
+    return types.Tool{Name: "place_order", InputSchema: schema}
+}
+
+func TestValidateToolInput(t *testing.T) {
+    tool := types.Tool{
+        Name: "get_weather",
+        InputSchema: types.InputSchema{
+            Type: "object",
+            Properties: map[string]types.Property{
+                "location": {Type: "string"},
+                "days":     {Type: "integer"},
+                "unit":     {Type: "string", Enum: []string{"celsius", "fahrenheit"}},
+                "detailed": {Type: "boolean"},
+            },
+            Required: []string{"location"},
+        },
+    }
+
+    tests := []struct {
+        name    string
+        input   string
+        wantErr string
+    }{
+        {name: "valid", input: `{"location":"Paris","days":3,"unit":"celsius","detailed":true}`},
+        {name: "only required", input: `{"location":"Paris"}`},
+        {name: "missing required", input: `{"days":3}`, wantErr: `missing required field "location"`},
+        {name: "wrong string type", input: `{"location":42}`, wantErr: `field "location": expected string, got number`},
+        {name: "fractional integer", input: `{"location":"Paris","days":2.5}`, wantErr: `field "days": expected integer, got number`},
+        {name: "wrong boolean type", input: `{"location":"Paris","detailed":"yes"}`, wantErr: `field "detailed": expected boolean, got string`},
+        {name: "enum", input: `{"location":"Paris","unit":"kelvin"}`, wantErr: `field "unit": value "kelvin" is not one of [celsius fahrenheit]`},
+        {name: "not an object", input: `["Paris"]`, wantErr: "input is not a JSON object"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := goanthropic.ValidateToolInput(tool, json.RawMessage(tt.input))
+            if tt.wantErr == "" {
+                if err != nil {
+                    t.Fatalf("ValidateToolInput: %v", err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestValidateToolInputNested(t *testing.T) {
+    tests := []struct {
+        name    string
+        input   string
+        wantErr string
+    }{
+        {
+            name:  "valid",
+            input: `{"customer":"Ada","ship":{"street":"1 Main St","city":"Springfield"},"items":[{"sku":"A1","quantity":2}],"status":"open"}`,
+        },
+        {
+            name:    "missing nested field",
+            input:   `{"customer":"Ada","ship":{"street":"1 Main St"},"items":[],"status":"open"}`,
+            wantErr: `field "ship": missing required field "city"`,
+        },
+        {
+            name:    "wrong nested type",
+            input:   `{"customer":"Ada","ship":{"street":"1 Main St","city":7},"items":[],"status":"open"}`,
+            wantErr: `field "ship": field "city": expected string, got number`,
+        },
+        {
+            name:    "wrong array item type",
+            input:   `{"customer":"Ada","ship":{"street":"1 Main St","city":"Springfield"},"items":[{"sku":"A1","quantity":2},{"sku":"B2","quantity":"two"}],"status":"open"}`,
+            wantErr: `field "items": item 1: field "quantity": expected integer, got string`,
+        },
+        {
+            name:    "missing field in array item",
+            input:   `{"customer":"Ada","ship":{"street":"1 Main St","city":"Springfield"},"items":[{"quantity":1}],"status":"open"}`,
+            wantErr: `field "items": item 0: missing required field "sku"`,
+        },
+        {
+            name:    "enum",
+            input:   `{"customer":"Ada","ship":{"street":"1 Main St","city":"Springfield"},"items":[],"status":"lost"}`,
+            wantErr: `field "status": value "lost" is not one of [open closed]`,
+        },
+    }
+    tool := orderTool(t)
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            err := goanthropic.ValidateToolInput(tool, json.RawMessage(tt.input))
+            if tt.wantErr == "" {
+                if err != nil {
+                    t.Fatalf("ValidateToolInput: %v", err)
+                }
+                return
+            }
+            if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+                t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+            }
+        })
+    }
+}
+
+func TestSchemaForInterfaceField(t *testing.T) {
+    type annotated struct {
+        Label string      `json:"label"`
+        Value interface{} `json:"value"`
+    }
+    schema, err := goanthropic.SchemaFor(annotated{})
+    if err != nil {
+        t.Fatalf("SchemaFor: %v", err)
+    }
+    data, _ := json.Marshal(schema.Properties["value"])
+    if string(data) != "{}" {
+        t.Errorf("interface field schema = %s, want {}", data)
+    }
+
+    tool := types.Tool{Name: "annotate", InputSchema: schema}
+    for _, value := range []string{`"high"`, `3.5`, `[1,"two"]`, `{"nested":true}`, `null`} {
+        input := `{"label":"priority","value":` + value + `}`
+        if err := goanthropic.ValidateToolInput(tool, json.RawMessage(input)); err != nil {
+            t.Errorf("ValidateToolInput(%s): %v", input, err)
+        }
+    }
+}
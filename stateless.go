@@ -0,0 +1,63 @@
+package goanthropic
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// WithStatelessMode stops the client from keeping a conversation. Every chat
+// call is built from its own message only, and neither the message nor the
+// reply is stored, so a client shared by many independent requests does not
+// accumulate history. Callers that want the model to see earlier messages
+// must supply them with each call through ChatWithHistory. Tool loops,
+// validation retries and usage statistics work as usual.
+func WithStatelessMode() ClientOption {
+    return func(c *AnthropicClient) {
+        c.stateless = true
+    }
+}
+
+// ChatWithHistory sends message after history and returns the assistant's
+// reply, leaving the client's stored conversation untouched. history is used
+// for this request only, so one client can serve many independent
+// conversations; append the user message and the returned reply to history
+// to continue. params is merged with the client defaults as in ChatMe.
+func (c *AnthropicClient) ChatWithHistory(ctx context.Context, history []types.Message, message string, params *types.MessageParams) (types.Message, error) {
+    call := c.detached(history)
+    if _, err := call.ChatMe(ctx, message, params); err != nil {
+        return types.Message{}, err
+    }
+
+    conversation := call.GetConversation()
+    if n := len(conversation); n > 0 && conversation[n-1].Role == types.RoleAssistant {
+        return conversation[n-1], nil
+    }
+    return types.Message{}, fmt.Errorf("no assistant reply was stored")
+}
+
+// detached returns a client for a single call that shares c's configuration,
+// lock and statistics but keeps its own conversation, starting from history.
+// The conversation observer is not told about its changes.
+func (c *AnthropicClient) detached(history []types.Message) *AnthropicClient {
+    c.mu.Lock()
+    call := *c
+    c.mu.Unlock()
+
+    call.parent = c.root()
+    call.stateless = false
+    call.conversation = append([]types.Message(nil), history...)
+    call.pendingEvents = nil
+    call.conversationObserver = nil
+    return &call
+}
+
+// root returns the client that owns the statistics: c itself, or the client
+// a detached client was created from
+func (c *AnthropicClient) root() *AnthropicClient {
+    if c.parent != nil {
+        return c.parent
+    }
+    return c
+}
@@ -0,0 +1,85 @@
+package goanthropic
+
+import (
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// defaultStatsWindow is the number of per-turn records kept when WithStatsWindow is not used
+const defaultStatsWindow = 100
+
+// toolStat holds cumulative result statistics for one tool
+type toolStat struct {
+    calls      int
+    totalBytes int
+    maxBytes   int
+}
+
+// WithStatsWindow limits how many per-turn records (token usage and tool
+// result sizes) the client keeps in memory. Cumulative totals are kept as
+// counters and keep growing regardless of the window, so long-running
+// processes use constant memory.
+func WithStatsWindow(size int) ClientOption {
+    return func(c *AnthropicClient) {
+        if size > 0 {
+            c.statsWindow = size
+        }
+    }
+}
+
+// TurnUsage returns the token usage of the most recent requests, oldest first,
+// up to the configured stats window
+func (c *AnthropicClient) TurnUsage() []types.Usage {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    usage := make([]types.Usage, len(c.turnUsage))
+    copy(usage, c.turnUsage)
+    return usage
+}
+
+// SessionUsage returns the input, output and cache token counts summed over
+// every request this client has made since it was created or ResetUsage was called
+func (c *AnthropicClient) SessionUsage() types.Usage {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.totalUsage
+}
+
+// ResetUsage zeroes the session usage totals, the per-turn usage history and
+// the usage of the last request
+func (c *AnthropicClient) ResetUsage() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.totalUsage = types.Usage{}
+    c.lastUsage = types.Usage{}
+    c.turnUsage = nil
+}
+
+// recordUsage stores the usage of a completed request
+func (c *AnthropicClient) recordUsage(usage types.Usage) {
+    c = c.root()
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    c.lastUsage = usage
+    c.turnUsage = appendWindowed(c.turnUsage, usage, c.statsWindow)
+    c.totalUsage.InputTokens += usage.InputTokens
+    c.totalUsage.OutputTokens += usage.OutputTokens
+    c.totalUsage.CacheCreationInputTokens += usage.CacheCreationInputTokens
+    c.totalUsage.CacheReadInputTokens += usage.CacheReadInputTokens
+}
+
+// appendWindowed appends item and drops the oldest entries beyond window.
+// Once the window is full the entries are shifted in place, so appending does
+// not allocate; the vacated slots are cleared so dropped entries can be freed.
+func appendWindowed[T any](items []T, item T, window int) []T {
+    if window > 0 && len(items) >= window {
+        n := copy(items, items[len(items)-window+1:])
+        var zero T
+        for i := n; i < len(items); i++ {
+            items[i] = zero
+        }
+        items = items[:n]
+    }
+    return append(items, item)
+}
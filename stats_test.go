@@ -0,0 +1,58 @@
+package goanthropic
+
+import (
+    "testing"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestAppendWindowed(t *testing.T) {
+    var items []int
+    for i := 1; i <= 5; i++ {
+        items = appendWindowed(items, i, 3)
+    }
+    if len(items) != 3 || items[0] != 3 || items[1] != 4 || items[2] != 5 {
+        t.Errorf("items = %v, want [3 4 5]", items)
+    }
+
+    allocs := testing.AllocsPerRun(100, func() {
+        items = appendWindowed(items, 6, 3)
+    })
+    if allocs != 0 {
+        t.Errorf("appending to a full window allocated %v times, want 0", allocs)
+    }
+}
+
+func TestTurnUsageWindow(t *testing.T) {
+    c := NewClient("test-key", WithStatsWindow(3))
+    for i := 0; i < 10; i++ {
+        c.recordUsage(types.Usage{InputTokens: 10, OutputTokens: i})
+    }
+
+    turns := c.TurnUsage()
+    if len(turns) != 3 {
+        t.Fatalf("kept %d turns, want 3", len(turns))
+    }
+    if turns[0].OutputTokens != 7 || turns[2].OutputTokens != 9 {
+        t.Errorf("turns = %+v, want the three most recent", turns)
+    }
+    if total := c.SessionUsage(); total.InputTokens != 100 || total.OutputTokens != 45 {
+        t.Errorf("session usage = %+v, want 100 input and 45 output tokens", total)
+    }
+}
+
+func TestResetUsage(t *testing.T) {
+    c := NewClient("test-key")
+    c.recordUsage(types.Usage{InputTokens: 10, OutputTokens: 5})
+    c.ResetUsage()
+
+    if total := c.SessionUsage(); total != (types.Usage{}) {
+        t.Errorf("session usage = %+v after reset", total)
+    }
+    if turns := c.TurnUsage(); len(turns) != 0 {
+        t.Errorf("kept %d turns after reset", len(turns))
+    }
+    if c.lastUsage != (types.Usage{}) {
+        t.Errorf("last usage = %+v after reset", c.lastUsage)
+    }
+}
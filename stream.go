@@ -0,0 +1,298 @@
+package goanthropic
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io/ioutil"
+    "net/http"
+    "strings"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// maxStreamLine is the longest SSE line accepted from a streamed response
+const maxStreamLine = 1024 * 1024
+
+// streamPayload is the union of the fields used by the streamed event types
+type streamPayload struct {
+    Type    string                  `json:"type"`
+    Index   int                     `json:"index"`
+    Message types.AnthropicResponse `json:"message"`
+    Block   types.MessageContent    `json:"content_block"`
+    Delta   struct {
+        Type        string `json:"type"`
+        Text        string `json:"text"`
+        PartialJSON string `json:"partial_json"`
+        Thinking    string `json:"thinking"`
+        Signature   string `json:"signature"`
+        StopReason  string `json:"stop_reason"`
+    } `json:"delta"`
+    Usage *types.Usage `json:"usage"`
+    Error struct {
+        Type    string `json:"type"`
+        Message string `json:"message"`
+    } `json:"error"`
+}
+
+// ChatStream sends a message and returns a channel of incremental events as
+// the response is generated. The channel is closed after the message_stop
+// event, after an error event, or when ctx is cancelled. Once the stream
+// completes the assembled response is added to the conversation history.
+func (c *AnthropicClient) ChatStream(ctx context.Context, message string, params *types.MessageParams) (<-chan types.StreamEvent, error) {
+    if c.stateless {
+        return c.detached(nil).ChatStream(ctx, message, params)
+    }
+    ctx, cancel := c.withDefaultDeadline(ctx)
+
+    finalParams := c.mergeParams(params)
+    limit := c.conversationLimit(finalParams)
+
+    content := []types.MessageContent{{
+        Type: types.ContentTypeText,
+        Text: message,
+    }}
+
+    c.addMessageToConversation(types.RoleUser, content)
+    c.trimConversationHistory(limit)
+
+    messages := c.conversationSnapshot()
+    reqBody := types.Request{
+        Model:         finalParams.Model,
+        System:        finalParams.System,
+        SystemBlocks:  finalParams.SystemBlocks,
+        Messages:      messages,
+        MaxTokens:     finalParams.MaxTokens,
+        Temperature:   finalParams.Temperature,
+        TopP:          finalParams.TopP,
+        TopK:          finalParams.TopK,
+        StopSequences: finalParams.StopSequences,
+        Metadata:      finalParams.Metadata,
+        Tools:         c.orderedTools(finalParams.Tools),
+        ToolChoice:    finalParams.ToolChoice,
+        Thinking:      finalParams.Thinking,
+        Stream:        true,
+    }
+
+    resp, err := c.openStream(ctx, reqBody)
+    if err != nil {
+        cancel()
+        c.recordError(err)
+        return nil, err
+    }
+
+    events := make(chan types.StreamEvent)
+    go func() {
+        defer cancel()
+        defer close(events)
+        defer resp.Body.Close()
+
+        emit := streamEmitter(ctx, events)
+        response, err := c.readStream(ctx, resp, emit)
+        if err != nil {
+            c.failStream(ctx, err, emit)
+            return
+        }
+        if content := c.assistantContent(response.Content); len(content) > 0 {
+            c.addMessageToConversation(types.RoleAssistant, content)
+            c.trimConversationHistory(limit)
+        }
+        emit(types.StreamEvent{Type: types.StreamEventMessageStop, Response: response})
+    }()
+    return events, nil
+}
+
+// streamEmitter returns a function that delivers an event on events, or
+// reports false once ctx is cancelled
+func streamEmitter(ctx context.Context, events chan<- types.StreamEvent) func(types.StreamEvent) bool {
+    return func(event types.StreamEvent) bool {
+        select {
+        case events <- event:
+            return true
+        case <-ctx.Done():
+            return false
+        }
+    }
+}
+
+// failStream records err and delivers it as an error event. Nothing is sent
+// once ctx is cancelled, since the caller has stopped listening.
+func (c *AnthropicClient) failStream(ctx context.Context, err error, emit func(types.StreamEvent) bool) {
+    if ctx.Err() != nil {
+        return
+    }
+    c.recordError(err)
+    emit(types.StreamEvent{Type: types.StreamEventError, Err: err})
+}
+
+// openStream sends a streaming request and returns the response once the
+// API has accepted it
+func (c *AnthropicClient) openStream(ctx context.Context, reqBody types.Request) (*http.Response, error) {
+    c.logMessage("Preparing streaming API request")
+    c.logJSON("Request payload", reqBody)
+
+    if err := c.validateModel(ctx, reqBody.Model); err != nil {
+        return nil, err
+    }
+    reqBody, err := c.prepareRequest(reqBody)
+    if err != nil {
+        return nil, err
+    }
+
+    jsonData, err := json.Marshal(reqBody)
+    if err != nil {
+        return nil, fmt.Errorf("error marshaling request: %w", err)
+    }
+    req, err := c.newAPIRequest(ctx, http.MethodPost, c.endpoint(messagesPath), jsonData, requestBetas(reqBody))
+    if err != nil {
+        return nil, fmt.Errorf("error creating request: %w", err)
+    }
+    req.Header.Set("Accept", "text/event-stream")
+
+    resp, err := c.streamingHTTPClient().Do(req)
+    if err != nil {
+        c.logError("API request failed: %v", err)
+        return nil, fmt.Errorf("error sending request: %w", err)
+    }
+    if resp.StatusCode != http.StatusOK {
+        defer resp.Body.Close()
+        body, err := ioutil.ReadAll(resp.Body)
+        if err != nil {
+            return nil, fmt.Errorf("error reading response: %w", err)
+        }
+        return nil, c.statusError(resp.StatusCode, body)
+    }
+    return resp, nil
+}
+
+// toolInputAccumulator buffers the input_json_delta fragments of each
+// streamed tool_use block by content block index, so blocks that stream
+// interleaved are assembled separately
+type toolInputAccumulator map[int]*strings.Builder
+
+// start begins buffering the input of the tool_use block at index
+func (a toolInputAccumulator) start(index int) {
+    a[index] = &strings.Builder{}
+}
+
+// add appends a fragment to the block at index, if it is a tool_use block
+func (a toolInputAccumulator) add(index int, fragment string) {
+    if buf, ok := a[index]; ok {
+        buf.WriteString(fragment)
+    }
+}
+
+// finish returns the complete input of the block at index. ok is false when
+// the block is not a tool_use; a tool without arguments has input {}.
+func (a toolInputAccumulator) finish(index int) (input json.RawMessage, ok bool, err error) {
+    buf, ok := a[index]
+    if !ok {
+        return nil, false, nil
+    }
+    delete(a, index)
+    data := buf.String()
+    if data == "" {
+        data = "{}"
+    }
+    if !json.Valid([]byte(data)) {
+        return nil, true, fmt.Errorf("streamed input for content block %d is not valid JSON", index)
+    }
+    return json.RawMessage(data), true, nil
+}
+
+// readStream parses SSE events from resp, forwarding text and completed tool
+// use events through emit, and returns the assembled response once
+// message_stop arrives
+func (c *AnthropicClient) readStream(ctx context.Context, resp *http.Response, emit func(types.StreamEvent) bool) (*types.AnthropicResponse, error) {
+    var response types.AnthropicResponse
+    partialInput := make(toolInputAccumulator)
+
+    scanner := bufio.NewScanner(resp.Body)
+    scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+    for scanner.Scan() {
+        line := scanner.Text()
+        if !strings.HasPrefix(line, "data:") {
+            continue
+        }
+        data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+        var payload streamPayload
+        if err := json.Unmarshal([]byte(data), &payload); err != nil {
+            return nil, fmt.Errorf("error parsing stream event: %w", err)
+        }
+
+        switch payload.Type {
+        case "message_start":
+            response = payload.Message
+            response.Content = nil
+
+        case "content_block_start":
+            block := payload.Block
+            for len(response.Content) <= payload.Index {
+                response.Content = append(response.Content, types.MessageContent{})
+            }
+            response.Content[payload.Index] = block
+            if block.Type == types.ContentTypeToolUse {
+                partialInput.start(payload.Index)
+            }
+
+        case "content_block_delta":
+            if payload.Index >= len(response.Content) {
+                return nil, fmt.Errorf("stream delta for unknown content block %d", payload.Index)
+            }
+            block := &response.Content[payload.Index]
+            switch payload.Delta.Type {
+            case "text_delta":
+                block.Text += payload.Delta.Text
+                if !emit(types.StreamEvent{Type: types.StreamEventText, Index: payload.Index, Text: payload.Delta.Text}) {
+                    return nil, ctx.Err()
+                }
+            case "input_json_delta":
+                partialInput.add(payload.Index, payload.Delta.PartialJSON)
+            case "thinking_delta":
+                block.Thinking += payload.Delta.Thinking
+            case "signature_delta":
+                block.Signature += payload.Delta.Signature
+            }
+
+        case "content_block_stop":
+            input, ok, err := partialInput.finish(payload.Index)
+            if err != nil {
+                return nil, err
+            }
+            if ok && payload.Index < len(response.Content) {
+                block := response.Content[payload.Index]
+                block.Input = input
+                response.Content[payload.Index] = block
+                if !emit(types.StreamEvent{Type: types.StreamEventToolUse, Index: payload.Index, ToolUse: &block}) {
+                    return nil, ctx.Err()
+                }
+            }
+
+        case "message_delta":
+            if payload.Delta.StopReason != "" {
+                response.StopReason = payload.Delta.StopReason
+            }
+            if payload.Usage != nil {
+                response.Usage.OutputTokens = payload.Usage.OutputTokens
+            }
+
+        case "message_stop":
+            c.logJSON("Streamed API response", response)
+            c.recordUsage(response.Usage)
+            return &response, nil
+
+        case "error":
+            return nil, &APIError{Type: payload.Error.Type, Message: payload.Error.Message}
+        }
+    }
+
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    if err := scanner.Err(); err != nil {
+        return nil, fmt.Errorf("error reading stream: %w", err)
+    }
+    return nil, fmt.Errorf("stream ended before message_stop")
+}
@@ -0,0 +1,322 @@
+package goanthropic
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "reflect"
+    "strings"
+    "time"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// structuredToolName is the tool the model is forced to call by ChatJSON
+const structuredToolName = "respond_with_json"
+
+// ChatJSON asks the model to answer message with JSON matching T and decodes
+// it. The model is forced to call a single tool whose input schema describes
+// T, which is far more reliable than asking for JSON in prose.
+//
+// The schema is generated from T's exported fields: json tags give the field
+// names, fields without omitempty are required, and optional description and
+// enum (comma separated) tags are copied into the schema. Nested structs and
+// slices are described field by field; maps only by their JSON type. To supply
+// a schema yourself, pass exactly one tool in params.Tools; its schema is used
+// instead.
+//
+// The conversation stores the answer as an assistant text turn holding the
+// JSON, so later calls can refer to it.
+func ChatJSON[T any](ctx context.Context, c *AnthropicClient, message string, params *types.MessageParams) (T, error) {
+    var out T
+
+    var p types.MessageParams
+    if params != nil {
+        p = *params
+    }
+    var tool types.Tool
+    if len(p.Tools) == 1 {
+        tool = p.Tools[0]
+    } else {
+        schema, err := SchemaFor(out)
+        if err != nil {
+            return out, err
+        }
+        tool = types.Tool{
+            Name:        structuredToolName,
+            Description: "Respond with the requested information as structured data.",
+            InputSchema: schema,
+        }
+    }
+    p.Tools = []types.Tool{tool}
+    p.ToolChoice = &types.ToolChoice{Type: types.ToolChoiceTool, Name: tool.Name}
+
+    input, err := c.chatStructured(ctx, message, &p)
+    if err != nil {
+        return out, err
+    }
+    if err := json.Unmarshal(input, &out); err != nil {
+        return out, fmt.Errorf("error decoding structured response: %w", err)
+    }
+    return out, nil
+}
+
+// chatStructured sends message with a forced tool choice and returns the
+// input the model gave that tool
+func (c *AnthropicClient) chatStructured(ctx context.Context, message string, params *types.MessageParams) (json.RawMessage, error) {
+    if c.stateless {
+        return c.detached(nil).chatStructured(ctx, message, params)
+    }
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    finalParams := c.mergeParams(params)
+    limit := c.conversationLimit(finalParams)
+    if err := validateToolParams(&finalParams); err != nil {
+        return nil, fmt.Errorf("invalid parameters: %w", err)
+    }
+
+    c.addMessageToConversation(types.RoleUser, []types.MessageContent{{
+        Type: types.ContentTypeText,
+        Text: message,
+    }})
+    c.trimConversationHistory(limit)
+
+    response, err := c.sendConversation(ctx, func() types.Request {
+        return types.Request{
+            Model:         finalParams.Model,
+            System:        finalParams.System,
+            SystemBlocks:  finalParams.SystemBlocks,
+            Messages:      c.conversationSnapshot(),
+            MaxTokens:     finalParams.MaxTokens,
+            Temperature:   finalParams.Temperature,
+            TopP:          finalParams.TopP,
+            TopK:          finalParams.TopK,
+            StopSequences: finalParams.StopSequences,
+            Metadata:      finalParams.Metadata,
+            Tools:         finalParams.Tools,
+            ToolChoice:    finalParams.ToolChoice,
+        }
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    for _, call := range response.ToolUses() {
+        if call.Name != finalParams.ToolChoice.Name {
+            continue
+        }
+        // Store the answer as text so the history has no unanswered tool_use
+        c.addMessageToConversation(types.RoleAssistant, []types.MessageContent{{
+            Type: types.ContentTypeText,
+            Text: string(call.Input),
+        }})
+        c.trimConversationHistory(limit)
+        return call.Input, nil
+    }
+    return nil, fmt.Errorf("response did not call %s (stop reason %s)", finalParams.ToolChoice.Name, response.StopReason)
+}
+
+// classifyToolName is the tool the model is forced to call by Classify
+const classifyToolName = "classify"
+
+// Classify labels each input with one of labels. Every input is sent as its
+// own request with a forced tool whose only argument is an enum of the labels,
+// so the answer is always machine readable. params is merged with the client
+// defaults; its System prompt is a good place to explain what the labels mean.
+// The client conversation is neither used nor changed, so
+// WithCompactOnRequestTooLarge does not apply to these requests.
+//
+// Labels are returned in input order. A response that does not pick exactly
+// one of the labels is an error.
+func (c *AnthropicClient) Classify(ctx context.Context, inputs []string, labels []string, params *types.MessageParams) ([]string, error) {
+    if len(labels) < 2 {
+        return nil, fmt.Errorf("at least two labels are required")
+    }
+
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    finalParams := c.mergeParams(params)
+    tool := types.Tool{
+        Name:        classifyToolName,
+        Description: "Classify the input with exactly one of the allowed labels.",
+        InputSchema: types.InputSchema{
+            Type: "object",
+            Properties: map[string]types.Property{
+                "label": {
+                    Type:        "string",
+                    Description: "The label that best fits the input",
+                    Enum:        labels,
+                },
+            },
+            Required: []string{"label"},
+        },
+    }
+
+    results := make([]string, len(inputs))
+    for i, input := range inputs {
+        reqBody := types.Request{
+            Model:        finalParams.Model,
+            System:       finalParams.System,
+            SystemBlocks: finalParams.SystemBlocks,
+            Messages: []types.Message{{
+                Role:    types.RoleUser,
+                Content: []types.MessageContent{{Type: types.ContentTypeText, Text: input}},
+            }},
+            MaxTokens:   finalParams.MaxTokens,
+            Temperature: finalParams.Temperature,
+            Metadata:    finalParams.Metadata,
+            Tools:       []types.Tool{tool},
+            ToolChoice:  &types.ToolChoice{Type: types.ToolChoiceTool, Name: classifyToolName},
+        }
+
+        response, err := c.sendRequest(ctx, reqBody)
+        if err != nil {
+            return nil, fmt.Errorf("input %d: %w", i, err)
+        }
+        label, err := classifyLabel(response, labels)
+        if err != nil {
+            return nil, fmt.Errorf("input %d: %w", i, err)
+        }
+        results[i] = label
+    }
+    return results, nil
+}
+
+// classifyLabel extracts the single label chosen in a Classify response
+func classifyLabel(response *types.AnthropicResponse, labels []string) (string, error) {
+    var calls []types.ToolUse
+    for _, call := range response.ToolUses() {
+        if call.Name == classifyToolName {
+            calls = append(calls, call)
+        }
+    }
+    if len(calls) != 1 {
+        return "", fmt.Errorf("expected one classification, got %d (stop reason %s)", len(calls), response.StopReason)
+    }
+
+    var answer struct {
+        Label string `json:"label"`
+    }
+    if err := json.Unmarshal(calls[0].Input, &answer); err != nil {
+        return "", fmt.Errorf("error decoding classification: %w", err)
+    }
+    for _, label := range labels {
+        if answer.Label == label {
+            return label, nil
+        }
+    }
+    return "", fmt.Errorf("classification %q is not one of the labels", answer.Label)
+}
+
+// SchemaFor builds a tool input schema from the exported fields of v, which
+// must be a struct or a pointer to one. See ChatJSON for the supported tags.
+func SchemaFor(v interface{}) (types.InputSchema, error) {
+    t := reflect.TypeOf(v)
+    for t != nil && t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    if t == nil || t.Kind() != reflect.Struct {
+        return types.InputSchema{}, fmt.Errorf("schema requires a struct type, got %v", t)
+    }
+
+    properties, required := structProperties(t, map[reflect.Type]bool{t: true})
+    return types.InputSchema{
+        Type:       "object",
+        Properties: properties,
+        Required:   required,
+    }, nil
+}
+
+// structProperties describes the exported fields of struct type t. seen holds
+// the struct types being described, so recursive types stop at a plain object.
+func structProperties(t reflect.Type, seen map[reflect.Type]bool) (map[string]types.Property, []string) {
+    properties := make(map[string]types.Property)
+    required := []string{}
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if field.PkgPath != "" {
+            continue
+        }
+
+        name := field.Name
+        optional := false
+        if tag := field.Tag.Get("json"); tag != "" {
+            parts := strings.Split(tag, ",")
+            if parts[0] == "-" {
+                continue
+            }
+            if parts[0] != "" {
+                name = parts[0]
+            }
+            for _, opt := range parts[1:] {
+                optional = optional || opt == "omitempty"
+            }
+        }
+
+        prop := propertyFor(field.Type, seen)
+        prop.Description = field.Tag.Get("description")
+        if enum := field.Tag.Get("enum"); enum != "" {
+            prop.Enum = strings.Split(enum, ",")
+        }
+        properties[name] = prop
+        if !optional && field.Type.Kind() != reflect.Ptr {
+            required = append(required, name)
+        }
+    }
+    return properties, required
+}
+
+// propertyFor describes values of type t, including the elements of slices
+// and the fields of nested structs
+func propertyFor(t reflect.Type, seen map[reflect.Type]bool) types.Property {
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    prop := types.Property{Type: jsonSchemaType(t)}
+    switch {
+    case prop.Type == "array":
+        items := propertyFor(t.Elem(), seen)
+        prop.Items = &items
+    case t.Kind() == reflect.Struct && !seen[t] && t != reflect.TypeOf(time.Time{}):
+        seen[t] = true
+        prop.Properties, prop.Required = structProperties(t, seen)
+        delete(seen, t)
+    }
+    return prop
+}
+
+// jsonSchemaType returns the JSON schema type used to encode values of t
+func jsonSchemaType(t reflect.Type) string {
+    for t.Kind() == reflect.Ptr {
+        t = t.Elem()
+    }
+    switch t.Kind() {
+    case reflect.String:
+        return "string"
+    case reflect.Bool:
+        return "boolean"
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return "integer"
+    case reflect.Float32, reflect.Float64:
+        return "number"
+    case reflect.Slice, reflect.Array:
+        if t.Elem().Kind() == reflect.Uint8 {
+            // []byte is encoded as a base64 string
+            return "string"
+        }
+        return "array"
+    case reflect.Struct:
+        if t == reflect.TypeOf(time.Time{}) {
+            return "string"
+        }
+        return "object"
+    case reflect.Interface:
+        // An interface holds any JSON value, so the schema has no type
+        return ""
+    default:
+        return "object"
+    }
+}
@@ -0,0 +1,51 @@
+package goanthropic_test
+
+import (
+    "context"
+    "net/http"
+    "reflect"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+)
+
+func TestClassify(t *testing.T) {
+    srv := anthropictest.NewServer(
+        anthropictest.ToolUseResponse("toolu_1", "classify", map[string]string{"label": "spam"}),
+        anthropictest.ToolUseResponse("toolu_2", "classify", map[string]string{"label": "ham"}),
+    )
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+
+    labels, err := client.Classify(context.Background(), []string{"Buy now!", "See you at lunch"}, []string{"spam", "ham"}, nil)
+    if err != nil {
+        t.Fatalf("Classify: %v", err)
+    }
+    if want := []string{"spam", "ham"}; !reflect.DeepEqual(labels, want) {
+        t.Errorf("labels = %v, want %v", labels, want)
+    }
+    srv.AssertTools(t, "classify")
+    if got := len(client.GetConversation()); got != 0 {
+        t.Errorf("conversation has %d messages, want 0", got)
+    }
+}
+
+func TestClassifyRequestTooLargeLeavesConversation(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("a"), anthropictest.TextResponse("b"))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"), goanthropic.WithCompactOnRequestTooLarge())
+    chatTurns(t, client, "one", "two")
+    before := client.GetConversation()
+
+    srv.EnqueueError(http.StatusRequestEntityTooLarge, "request_too_large", "too big")
+    if _, err := client.Classify(context.Background(), []string{"input"}, []string{"spam", "ham"}, nil); err == nil {
+        t.Fatal("expected the request too large error")
+    }
+    if after := client.GetConversation(); !reflect.DeepEqual(after, before) {
+        t.Errorf("conversation changed from %d to %d messages", len(before), len(after))
+    }
+    if got := len(srv.Requests()); got != 3 {
+        t.Errorf("sent %d requests, want 3 without a retry", got)
+    }
+}
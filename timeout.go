@@ -0,0 +1,94 @@
+package goanthropic
+
+import (
+    "context"
+    "net/http"
+    "time"
+)
+
+// defaultHTTPTimeout bounds each request made by the default HTTP client. It
+// is long enough for large generations while still ending hung connections.
+const defaultHTTPTimeout = 10 * time.Minute
+
+// WithTimeout sets the overall timeout of each HTTP request made by the
+// client's default HTTP client; zero disables it. A client supplied with
+// WithHTTPClient keeps its own Timeout. Streaming calls are exempt because a
+// long generation can legitimately stream for longer than the timeout; bound
+// them with a context deadline instead.
+func WithTimeout(timeout time.Duration) ClientOption {
+    return func(c *AnthropicClient) {
+        if timeout >= 0 {
+            c.httpTimeout = timeout
+        }
+    }
+}
+
+// streamingHTTPClient returns a copy of the HTTP client without an overall
+// timeout, sharing its transport, for reading streamed responses
+func (c *AnthropicClient) streamingHTTPClient() *http.Client {
+    if c.httpClient.Timeout == 0 {
+        return c.httpClient
+    }
+    client := *c.httpClient
+    client.Timeout = 0
+    return &client
+}
+
+// WithDefaultContextTimeout bounds every call whose context has no deadline,
+// such as context.Background(). Contexts that already carry a deadline are
+// left untouched, so explicit deadlines always win.
+func WithDefaultContextTimeout(timeout time.Duration) ClientOption {
+    return func(c *AnthropicClient) {
+        if timeout > 0 {
+            c.defaultCtxTimeout = timeout
+        }
+    }
+}
+
+// WithDefaultRequestTimeout bounds each API request sent without a deadline.
+// Unlike WithDefaultContextTimeout, which bounds a whole call including every
+// round of a tool loop, it applies to every request separately. A deadline
+// already set by the caller, or by WithDefaultContextTimeout, is respected.
+func WithDefaultRequestTimeout(timeout time.Duration) ClientOption {
+    return func(c *AnthropicClient) {
+        if timeout > 0 {
+            c.requestTimeout = timeout
+        }
+    }
+}
+
+// withRequestDeadline applies the default request timeout to a context without
+// a deadline. The returned cancel function must always be called.
+func (c *AnthropicClient) withRequestDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+    if c.requestTimeout <= 0 {
+        return ctx, func() {}
+    }
+    if _, ok := ctx.Deadline(); ok {
+        return ctx, func() {}
+    }
+    return context.WithTimeout(ctx, c.requestTimeout)
+}
+
+// withDefaultDeadline applies the default timeout to a context without a deadline.
+// The returned cancel function must always be called.
+func (c *AnthropicClient) withDefaultDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+    if c.defaultCtxTimeout <= 0 {
+        return ctx, func() {}
+    }
+    if _, ok := ctx.Deadline(); ok {
+        return ctx, func() {}
+    }
+    return context.WithTimeout(ctx, c.defaultCtxTimeout)
+}
+
+// sleepContext waits for d or until ctx is done, whichever comes first
+func sleepContext(ctx context.Context, d time.Duration) error {
+    timer := time.NewTimer(d)
+    defer timer.Stop()
+    select {
+    case <-timer.C:
+        return nil
+    case <-ctx.Done():
+        return ctx.Err()
+    }
+}
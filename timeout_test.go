@@ -0,0 +1,64 @@
+package goanthropic_test
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+)
+
+// slowServer answers every request with a text response after delay
+func slowServer(delay time.Duration) *httptest.Server {
+    return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case <-time.After(delay):
+        case <-r.Context().Done():
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(anthropictest.TextResponse("Hello"))
+    }))
+}
+
+func TestDefaultContextTimeoutWithoutDeadline(t *testing.T) {
+    srv := slowServer(2 * time.Second)
+    defer srv.Close()
+    client := goanthropic.NewClient("test-key",
+        goanthropic.WithBaseURL(srv.URL),
+        goanthropic.WithDefaultContextTimeout(50*time.Millisecond),
+    )
+
+    start := time.Now()
+    _, err := client.ChatMe(context.Background(), "Hi", nil)
+    if !errors.Is(err, context.DeadlineExceeded) {
+        t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Errorf("call took %v, want it cut off by the default timeout", elapsed)
+    }
+}
+
+func TestDefaultContextTimeoutKeepsExplicitDeadline(t *testing.T) {
+    srv := slowServer(200 * time.Millisecond)
+    defer srv.Close()
+    client := goanthropic.NewClient("test-key",
+        goanthropic.WithBaseURL(srv.URL),
+        goanthropic.WithDefaultContextTimeout(50*time.Millisecond),
+    )
+
+    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    defer cancel()
+    resp, err := client.ChatMe(ctx, "Hi", nil)
+    if err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if resp.Text() != "Hello" {
+        t.Errorf("reply = %q", resp.Text())
+    }
+}
@@ -0,0 +1,104 @@
+package goanthropic
+
+import (
+    "encoding/json"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+const (
+    // charsPerToken is the rough ratio used to estimate tokens from serialized content
+    charsPerToken = 4
+    // imageTokenEstimate approximates the cost of one image block
+    imageTokenEstimate = 1600
+    // documentTokenEstimate approximates the cost of one PDF document block,
+    // roughly a page of text and its image
+    documentTokenEstimate = 3000
+)
+
+// WithMaxTokens trims the oldest messages until the estimated token count of
+// the stored conversation fits within budget. Unlike WithMaxConversationLength
+// it accounts for message size, so a few large tool results cannot overflow
+// the context window. The estimate is a local heuristic of about four
+// characters per token, with a fixed estimate for each image and PDF document
+// wherever it appears, and does not include the system prompt or tools; use
+// CountTokens for exact figures. The most recent turn is always kept, and
+// tool_use/tool_result pairs are never separated.
+func WithMaxTokens(budget int) ClientOption {
+    return func(c *AnthropicClient) {
+        if budget > 0 {
+            c.maxConvTokens = budget
+        }
+    }
+}
+
+// trimToTokenBudget drops the oldest messages, at safe boundaries, until the
+// conversation fits the token budget. The caller must hold c.mu.
+func (c *AnthropicClient) trimToTokenBudget() {
+    if c.maxConvTokens <= 0 {
+        return
+    }
+
+    total := 0
+    estimates := make([]int, len(c.conversation))
+    for i, msg := range c.conversation {
+        estimates[i] = estimateMessageTokens(msg)
+        total += estimates[i]
+    }
+    if total <= c.maxConvTokens {
+        return
+    }
+
+    removed := 0
+    for total > c.maxConvTokens {
+        next := safeStartIndex(c.conversation, removed+1)
+        if next >= len(c.conversation) {
+            break
+        }
+        for _, estimate := range estimates[removed:next] {
+            total -= estimate
+        }
+        removed = next
+    }
+    if removed == 0 {
+        return
+    }
+
+    c.logMessage("Trimming %d messages to fit token budget: %d", removed, c.maxConvTokens)
+    c.conversation = c.conversation[removed:]
+    c.notifyConversation(types.ConversationEvent{Type: types.ConversationEventTrim, Removed: removed})
+}
+
+// estimateMessageTokens approximates the tokens a message uses
+func estimateMessageTokens(msg types.Message) int {
+    tokens := 0
+    for _, block := range msg.Content {
+        tokens += estimateBlockTokens(block)
+    }
+    return tokens
+}
+
+// estimateBlockTokens approximates the tokens a content block uses. Media is
+// counted at a fixed estimate rather than by the length of its base64 data,
+// including media nested in a tool result.
+func estimateBlockTokens(block types.MessageContent) int {
+    switch block.Type {
+    case types.ContentTypeImage:
+        return imageTokenEstimate
+    case types.ContentTypeDocument:
+        return documentTokenEstimate
+    }
+
+    tokens := 0
+    if block.ContentBlocks != nil {
+        for _, nested := range block.ContentBlocks {
+            tokens += estimateBlockTokens(nested)
+        }
+        block.ContentBlocks = nil
+    }
+    data, err := json.Marshal(block)
+    if err != nil {
+        return tokens
+    }
+    return tokens + (len(data)+charsPerToken-1)/charsPerToken
+}
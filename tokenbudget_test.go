@@ -0,0 +1,40 @@
+package goanthropic
+
+import (
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestEstimateMessageTokensMedia(t *testing.T) {
+    data := strings.Repeat("A", 400000)
+    image := types.MessageContent{Type: types.ContentTypeImage, Source: &types.ImageSource{Type: types.ImageSourceBase64, MediaType: types.MediaTypePNG, Data: data}}
+    document := types.MessageContent{Type: types.ContentTypeDocument, Source: &types.ImageSource{Type: types.ImageSourceBase64, MediaType: types.MediaTypePDF, Data: data}}
+    result := types.MessageContent{
+        Type:          types.ContentTypeToolResult,
+        ToolUseID:     "toolu_1",
+        ContentBlocks: []types.MessageContent{{Type: types.ContentTypeText, Text: "chart"}, image},
+    }
+
+    tests := []struct {
+        name  string
+        block types.MessageContent
+        max   int
+    }{
+        {"image", image, imageTokenEstimate},
+        {"document", document, documentTokenEstimate},
+        {"tool result with image", result, imageTokenEstimate + 100},
+    }
+    for _, tt := range tests {
+        got := estimateMessageTokens(types.Message{Role: types.RoleUser, Content: []types.MessageContent{tt.block}})
+        if got > tt.max {
+            t.Errorf("%s: estimate = %d, want at most %d", tt.name, got, tt.max)
+        }
+    }
+
+    text := types.MessageContent{Type: types.ContentTypeText, Text: strings.Repeat("word ", 1000)}
+    if got := estimateMessageTokens(types.Message{Role: types.RoleUser, Content: []types.MessageContent{text}}); got < 1000 {
+        t.Errorf("text estimate = %d, want at least 1000", got)
+    }
+}
@@ -0,0 +1,175 @@
+package goanthropic
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sync"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// defaultTokenCountConcurrency limits the parallel requests made by CountTokensBatch
+const defaultTokenCountConcurrency = 4
+
+// TokenCountError reports which entries of a CountTokensBatch call failed
+type TokenCountError struct {
+    // Errors holds one entry per input, nil where counting succeeded
+    Errors []error
+}
+
+func (e *TokenCountError) Error() string {
+    failed := 0
+    first := -1
+    for i, err := range e.Errors {
+        if err != nil {
+            failed++
+            if first < 0 {
+                first = i
+            }
+        }
+    }
+    if first < 0 {
+        return "token counting failed"
+    }
+    return fmt.Sprintf("token counting failed for %d of %d inputs (input %d: %v)",
+        failed, len(e.Errors), first, e.Errors[first])
+}
+
+// Unwrap returns the individual failures so errors.Is and errors.As can inspect them
+func (e *TokenCountError) Unwrap() []error {
+    var errs []error
+    for _, err := range e.Errors {
+        if err != nil {
+            errs = append(errs, err)
+        }
+    }
+    return errs
+}
+
+// WithTokenCountConcurrency sets how many token counting requests
+// CountTokensBatch may have in flight at once
+func WithTokenCountConcurrency(limit int) ClientOption {
+    return func(c *AnthropicClient) {
+        if limit > 0 {
+            c.tokenCountConcurrency = limit
+        }
+    }
+}
+
+// CountTokens returns the number of input tokens the current conversation and
+// system prompt would use, without generating a response. params is merged with
+// the client defaults; when params.Messages is set those messages are counted
+// instead of the conversation. Use it to trim before a request would exceed the
+// model's context window.
+func (c *AnthropicClient) CountTokens(ctx context.Context, params *types.MessageParams) (int, error) {
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    count, err := c.countTokens(ctx, c.countTokensRequest(params))
+    if err != nil {
+        c.recordError(err)
+        return 0, err
+    }
+    return count, nil
+}
+
+// CountTokensBatch counts the input tokens of each entry in inputs without
+// generating a response. Each entry is merged with the client defaults; entries
+// without Messages are counted against the current conversation.
+//
+// Counts are returned in input order. If any entry fails, the counts of the
+// successful entries are still returned together with a *TokenCountError
+// describing each failure by index.
+func (c *AnthropicClient) CountTokensBatch(ctx context.Context, inputs []types.MessageParams) ([]int, error) {
+    ctx, cancel := c.withDefaultDeadline(ctx)
+    defer cancel()
+
+    // Build every request up front so workers never touch client state
+    requests := make([]types.CountTokensRequest, len(inputs))
+    for i := range inputs {
+        requests[i] = c.countTokensRequest(&inputs[i])
+    }
+
+    limit := c.tokenCountConcurrency
+    if limit <= 0 {
+        limit = defaultTokenCountConcurrency
+    }
+
+    counts := make([]int, len(inputs))
+    errs := make([]error, len(inputs))
+    sem := make(chan struct{}, limit)
+    var wg sync.WaitGroup
+
+    for i := range requests {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            select {
+            case sem <- struct{}{}:
+                defer func() { <-sem }()
+            case <-ctx.Done():
+                errs[i] = ctx.Err()
+                return
+            }
+            counts[i], errs[i] = c.countTokens(ctx, requests[i])
+        }(i)
+    }
+    wg.Wait()
+
+    for _, err := range errs {
+        if err != nil {
+            return counts, &TokenCountError{Errors: errs}
+        }
+    }
+    return counts, nil
+}
+
+// countTokensRequest builds a token counting request from params merged with
+// the client defaults. The system prompt and tools carry the same blocks and
+// cache markers as a messages request would.
+func (c *AnthropicClient) countTokensRequest(params *types.MessageParams) types.CountTokensRequest {
+    finalParams := c.mergeParams(params)
+    messages := c.conversationSnapshot()
+    if params != nil && len(params.Messages) > 0 {
+        messages = params.Messages
+    }
+
+    marked := c.applyCacheMarkers(types.Request{
+        System:       finalParams.System,
+        SystemBlocks: finalParams.SystemBlocks,
+        Tools:        c.orderedTools(finalParams.Tools),
+    })
+    return types.CountTokensRequest{
+        Model:              finalParams.Model,
+        Messages:           messages,
+        System:             marked.System,
+        SystemBlocks:       marked.SystemBlocks,
+        SystemCacheControl: marked.SystemCacheControl,
+        Tools:              marked.Tools,
+        ToolChoice:         finalParams.ToolChoice,
+    }
+}
+
+// countTokens posts a request to the token counting endpoint
+func (c *AnthropicClient) countTokens(ctx context.Context, req types.CountTokensRequest) (int, error) {
+    if req.Model == "" {
+        return 0, fmt.Errorf("model is required to count tokens")
+    }
+    if len(req.Messages) == 0 {
+        return 0, fmt.Errorf("at least one message is required to count tokens")
+    }
+
+    c.logJSON("Count tokens payload", req)
+    body, err := c.postJSON(ctx, c.endpoint(countTokensPath), req, nil)
+    if err != nil {
+        return 0, err
+    }
+
+    var countResp types.CountTokensResponse
+    if err := json.Unmarshal(body, &countResp); err != nil {
+        c.logError("Error parsing count tokens response: %v", err)
+        return 0, fmt.Errorf("error parsing response: %w", err)
+    }
+    return countResp.InputTokens, nil
+}
@@ -0,0 +1,143 @@
+package goanthropic_test
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestCountTokensSendsSystemBlocks(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"), goanthropic.WithSystemPromptCache(types.CacheTTL5m))
+
+    _, err := client.CountTokens(context.Background(), &types.MessageParams{
+        SystemBlocks: []types.SystemBlock{{Text: "Static preamble. "}, {Text: "Today is Monday."}},
+        Messages:     []types.Message{{Role: types.RoleUser, Content: []types.MessageContent{{Type: types.ContentTypeText, Text: "Hi"}}}},
+    })
+    if err != nil {
+        t.Fatalf("CountTokens: %v", err)
+    }
+
+    req := srv.CountRequests()[0]
+    if req.System != "Static preamble. Today is Monday." {
+        t.Errorf("system = %q, want both blocks", req.System)
+    }
+    if len(req.SystemBlocks) != 2 {
+        t.Fatalf("sent %d system blocks, want 2", len(req.SystemBlocks))
+    }
+    if req.SystemBlocks[1].CacheControl == nil {
+        t.Error("the last system block has no cache marker")
+    }
+}
+
+func TestCountTokensBatchUsesSystemPrompt(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"), goanthropic.WithSystemPrompt("You are terse."))
+
+    hi := []types.Message{{Role: types.RoleUser, Content: []types.MessageContent{{Type: types.ContentTypeText, Text: "Hi"}}}}
+    counts, err := client.CountTokensBatch(context.Background(), []types.MessageParams{{Messages: hi}, {Messages: hi}})
+    if err != nil {
+        t.Fatalf("CountTokensBatch: %v", err)
+    }
+    if len(counts) != 2 {
+        t.Fatalf("got %d counts, want 2", len(counts))
+    }
+    for i, req := range srv.CountRequests() {
+        if req.System != "You are terse." {
+            t.Errorf("request %d system = %q", i, req.System)
+        }
+    }
+}
+
+// userText returns a single user message holding text
+func userText(text string) []types.Message {
+    return []types.Message{{Role: types.RoleUser, Content: []types.MessageContent{{Type: types.ContentTypeText, Text: text}}}}
+}
+
+func TestCountTokensBatchReportsErrorsByIndex(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    srv.SetTokenCounter(func(req types.CountTokensRequest) int {
+        return len(req.Messages[0].Content[0].Text)
+    })
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+
+    inputs := []types.MessageParams{
+        {Messages: userText("a")},
+        {},
+        {Messages: userText("abc")},
+    }
+    counts, err := client.CountTokensBatch(context.Background(), inputs)
+
+    var countErr *goanthropic.TokenCountError
+    if !errors.As(err, &countErr) {
+        t.Fatalf("err = %v, want a *TokenCountError", err)
+    }
+    if countErr.Errors[0] != nil || countErr.Errors[2] != nil {
+        t.Errorf("errors = %v, want only index 1 to fail", countErr.Errors)
+    }
+    if countErr.Errors[1] == nil || !strings.Contains(countErr.Errors[1].Error(), "at least one message") {
+        t.Errorf("index 1 error = %v", countErr.Errors[1])
+    }
+    if counts[0] != 1 || counts[1] != 0 || counts[2] != 3 {
+        t.Errorf("counts = %v, want [1 0 3]", counts)
+    }
+}
+
+func TestCountTokensBatchRespectsConcurrency(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+    var mu sync.Mutex
+    inFlight, peak := 0, 0
+    srv.SetTokenCounter(func(req types.CountTokensRequest) int {
+        mu.Lock()
+        inFlight++
+        if inFlight > peak {
+            peak = inFlight
+        }
+        mu.Unlock()
+        time.Sleep(20 * time.Millisecond)
+        mu.Lock()
+        inFlight--
+        mu.Unlock()
+        return 1
+    })
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"), goanthropic.WithTokenCountConcurrency(2))
+
+    inputs := make([]types.MessageParams, 6)
+    for i := range inputs {
+        inputs[i].Messages = userText("Hi")
+    }
+    counts, err := client.CountTokensBatch(context.Background(), inputs)
+    if err != nil {
+        t.Fatalf("CountTokensBatch: %v", err)
+    }
+    if len(counts) != len(inputs) {
+        t.Fatalf("got %d counts, want %d", len(counts), len(inputs))
+    }
+    if peak > 2 {
+        t.Errorf("%d requests in flight at once, want at most 2", peak)
+    }
+}
+
+func TestPingWithoutModel(t *testing.T) {
+    srv := anthropictest.NewServer()
+    defer srv.Close()
+
+    if err := srv.Client().Ping(context.Background()); err != nil {
+        t.Fatalf("Ping: %v", err)
+    }
+    reqs := srv.CountRequests()
+    if len(reqs) != 1 || reqs[0].Model == "" {
+        t.Errorf("count requests = %+v, want one naming the default model", reqs)
+    }
+}
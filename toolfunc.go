@@ -0,0 +1,84 @@
+package goanthropic
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "reflect"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+var (
+    contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+    errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToolFromFunc builds a tool and its handler from a Go function, generating
+// the input schema from the function's argument as SchemaFor does.
+//
+// fn takes a struct (or pointer to one), optionally preceded by a
+// context.Context, and returns a result, optionally followed by an error:
+//
+//	func(ctx context.Context, in WeatherInput) (WeatherReport, error)
+//
+// The tool input is decoded into the struct before each call. A string result
+// is sent to the model as it is; any other result is encoded as JSON.
+func ToolFromFunc(name, description string, fn interface{}) (types.Tool, types.ToolHandler, error) {
+    v := reflect.ValueOf(fn)
+    if v.Kind() != reflect.Func || v.IsNil() {
+        return types.Tool{}, nil, fmt.Errorf("tool %s: expected a function, got %T", name, fn)
+    }
+    t := v.Type()
+
+    withContext := t.NumIn() == 2 && t.In(0) == contextType
+    if t.NumIn() != 1 && !withContext {
+        return types.Tool{}, nil, fmt.Errorf("tool %s: function must take one struct argument, optionally after a context", name)
+    }
+    inType := t.In(t.NumIn() - 1)
+    withError := t.NumOut() == 2 && t.Out(1) == errorType
+    if t.NumOut() != 1 && !withError {
+        return types.Tool{}, nil, fmt.Errorf("tool %s: function must return a result, optionally followed by an error", name)
+    }
+
+    schema, err := SchemaFor(reflect.Zero(inType).Interface())
+    if err != nil {
+        return types.Tool{}, nil, fmt.Errorf("tool %s: %w", name, err)
+    }
+    tool := types.Tool{
+        Name:        name,
+        Description: description,
+        InputSchema: schema,
+    }
+
+    call := func(ctx context.Context, input json.RawMessage) (string, error) {
+        in := reflect.New(inType)
+        if inType.Kind() == reflect.Ptr {
+            in.Elem().Set(reflect.New(inType.Elem()))
+        }
+        if len(input) > 0 {
+            if err := json.Unmarshal(input, in.Interface()); err != nil {
+                return "", fmt.Errorf("error decoding input for tool %s: %w", name, err)
+            }
+        }
+
+        args := []reflect.Value{in.Elem()}
+        if withContext {
+            args = append([]reflect.Value{reflect.ValueOf(ctx)}, args...)
+        }
+        out := v.Call(args)
+        if withError && !out[1].IsNil() {
+            return "", out[1].Interface().(error)
+        }
+
+        if s, ok := out[0].Interface().(string); ok {
+            return s, nil
+        }
+        data, err := json.Marshal(out[0].Interface())
+        if err != nil {
+            return "", fmt.Errorf("error encoding result of tool %s: %w", name, err)
+        }
+        return string(data), nil
+    }
+    return tool, types.ToolHandlerFunc{Tool: tool, Func: call}, nil
+}
@@ -0,0 +1,107 @@
+package goanthropic
+
+import (
+    "bytes"
+    "encoding/json"
+    "errors"
+    "fmt"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// ErrToolLoop is matched by errors.Is when ChatWithTools stops because the
+// model kept making the same tool call
+var ErrToolLoop = errors.New("model is repeating the same tool call")
+
+// ToolLoopMode controls what happens when loop detection finds a repeated call
+type ToolLoopMode int
+
+const (
+    // ToolLoopAbort stops the chat with an error wrapping ErrToolLoop (the default)
+    ToolLoopAbort ToolLoopMode = iota
+    // ToolLoopNote runs the call but appends a note to its result telling the
+    // model it is repeating itself, so it can change course
+    ToolLoopNote
+)
+
+// WithToolLoopDetection watches ChatWithTools for a tool being called again
+// and again with identical input. A call made more than maxRepeats times with
+// the same name and input within one chat is handled as set by
+// WithToolLoopMode. Zero, the default, disables detection.
+func WithToolLoopDetection(maxRepeats int) ClientOption {
+    return func(c *AnthropicClient) {
+        if maxRepeats > 0 {
+            c.toolLoopLimit = maxRepeats
+        }
+    }
+}
+
+// WithToolLoopMode sets whether a detected tool loop aborts the chat or is
+// pointed out to the model
+func WithToolLoopMode(mode ToolLoopMode) ClientOption {
+    return func(c *AnthropicClient) {
+        c.toolLoopMode = mode
+    }
+}
+
+// toolLoopDetector counts identical tool calls across the turns of one chat
+type toolLoopDetector struct {
+    limit  int
+    mode   ToolLoopMode
+    counts map[string]int
+}
+
+// newToolLoopDetector returns a detector for a single chat, or nil when
+// detection is disabled
+func (c *AnthropicClient) newToolLoopDetector() *toolLoopDetector {
+    if c.toolLoopLimit <= 0 {
+        return nil
+    }
+    return &toolLoopDetector{limit: c.toolLoopLimit, mode: c.toolLoopMode, counts: make(map[string]int)}
+}
+
+// observe records a turn's calls. In abort mode it returns an error for the
+// first call over the limit; in note mode it returns the indexes of the calls
+// whose results should carry a note.
+func (d *toolLoopDetector) observe(calls []types.ToolUse) ([]int, error) {
+    if d == nil {
+        return nil, nil
+    }
+    var repeated []int
+    for i, call := range calls {
+        key := call.Name + "\x00" + compactJSON(call.Input)
+        d.counts[key]++
+        if d.counts[key] <= d.limit {
+            continue
+        }
+        if d.mode == ToolLoopAbort {
+            return nil, fmt.Errorf("%w: %s called %d times with the same input", ErrToolLoop, call.Name, d.counts[key])
+        }
+        repeated = append(repeated, i)
+    }
+    return repeated, nil
+}
+
+// noteRepeats appends a loop warning to the tool results at the given indexes
+func noteRepeats(results []types.MessageContent, repeated []int) {
+    const note = "Note: this tool has already been called with exactly this input and the result will not change. Try a different approach or answer with the information you have."
+    for _, i := range repeated {
+        if results[i].ContentBlocks != nil {
+            results[i].ContentBlocks = append(results[i].ContentBlocks, types.MessageContent{
+                Type: types.ContentTypeText,
+                Text: note,
+            })
+        } else {
+            results[i].Content += "\n\n" + note
+        }
+    }
+}
+
+// compactJSON normalizes whitespace so equal inputs compare equal
+func compactJSON(data json.RawMessage) string {
+    var buf bytes.Buffer
+    if err := json.Compact(&buf, data); err != nil {
+        return string(data)
+    }
+    return buf.String()
+}
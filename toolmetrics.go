@@ -0,0 +1,146 @@
+package goanthropic
+
+import (
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// WithToolResultWarnBytes emits a warning whenever a tool result exceeds the
+// given size in bytes. Large results inflate cost and can push requests past
+// the context window.
+func WithToolResultWarnBytes(limit int) ClientOption {
+    return func(c *AnthropicClient) {
+        if limit > 0 {
+            c.toolResultWarnBytes = limit
+        }
+    }
+}
+
+// WithToolResultContentType chooses how tool results are framed: as a plain
+// string (types.ToolResultFormatString, the default) or as an array holding a
+// single text block (types.ToolResultFormatBlocks). Some models and accounts
+// expect one shape over the other.
+func WithToolResultContentType(format string) ClientOption {
+    return func(c *AnthropicClient) {
+        switch format {
+        case types.ToolResultFormatString, types.ToolResultFormatBlocks:
+            c.toolResultFormat = format
+        default:
+            c.logMessage("Ignoring unknown tool result format: %s", format)
+        }
+    }
+}
+
+// newToolResult builds a tool_result block in the configured framing
+func (c *AnthropicClient) newToolResult(toolUseID, result string, isError bool) types.MessageContent {
+    content := types.MessageContent{
+        Type:      types.ContentTypeToolResult,
+        ToolUseID: toolUseID,
+        IsError:   isError,
+    }
+    if c.toolResultFormat == types.ToolResultFormatBlocks {
+        content.ContentBlocks = []types.MessageContent{{
+            Type: types.ContentTypeText,
+            Text: result,
+        }}
+    } else {
+        content.Content = result
+    }
+    return content
+}
+
+// ToolResultMetrics returns the sizes of the most recent tool results sent by
+// this client, up to the window set with WithStatsWindow
+func (c *AnthropicClient) ToolResultMetrics() []types.ToolResultMetric {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    metrics := make([]types.ToolResultMetric, len(c.toolResultMetrics))
+    copy(metrics, c.toolResultMetrics)
+    return metrics
+}
+
+// LastToolInteractions returns the tool calls made during the most recent
+// ChatWithTools call, grouped by loop iteration and paired with their results.
+// The record is kept per client, not per call: each call clears it when it
+// starts, so calls running concurrently on one client overwrite and interleave
+// their records. Use WithToolObserver, or a client per goroutine, to attribute
+// tool calls when chatting concurrently.
+func (c *AnthropicClient) LastToolInteractions() []types.ToolInteraction {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    interactions := make([]types.ToolInteraction, len(c.lastInteractions))
+    copy(interactions, c.lastInteractions)
+    return interactions
+}
+
+// LastToolTrace returns every tool call made during the most recent
+// ChatWithTools or ChatWithToolsStream call, in the order the calls were
+// made, with their results and durations. Like LastToolInteractions it only
+// describes a single call when no other call runs concurrently.
+func (c *AnthropicClient) LastToolTrace() []types.ToolEvent {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    trace := make([]types.ToolEvent, len(c.lastToolTrace))
+    copy(trace, c.lastToolTrace)
+    return trace
+}
+
+// resetToolRecords clears the calls kept for LastToolInteractions and LastToolTrace
+func (c *AnthropicClient) resetToolRecords() {
+    c = c.root()
+    c.mu.Lock()
+    c.lastInteractions = nil
+    c.lastToolTrace = nil
+    c.mu.Unlock()
+}
+
+// recordToolResultSize tracks the size of a tool result and warns when it is over the threshold
+func (c *AnthropicClient) recordToolResultSize(call types.ToolUse, result types.MessageContent) {
+    c = c.root()
+    metric := types.ToolResultMetric{
+        ToolName:  call.Name,
+        ToolUseID: call.ID,
+        Bytes:     toolResultBytes(result),
+    }
+
+    c.mu.Lock()
+    c.toolResultMetrics = appendWindowed(c.toolResultMetrics, metric, c.statsWindow)
+
+    if c.toolStats == nil {
+        c.toolStats = make(map[string]*toolStat)
+    }
+    stat, ok := c.toolStats[metric.ToolName]
+    if !ok {
+        stat = &toolStat{}
+        c.toolStats[metric.ToolName] = stat
+    }
+    stat.calls++
+    stat.totalBytes += metric.Bytes
+    if metric.Bytes > stat.maxBytes {
+        stat.maxBytes = metric.Bytes
+    }
+    c.mu.Unlock()
+
+    if c.toolResultWarnBytes > 0 && metric.Bytes > c.toolResultWarnBytes {
+        c.warn("tool %q returned %d bytes, exceeding the %d byte warning threshold",
+            metric.ToolName, metric.Bytes, c.toolResultWarnBytes)
+    }
+}
+
+// toolResultBytes returns the size of a tool_result block: its text plus the
+// base64 data or URL of any image or document it carries
+func toolResultBytes(result types.MessageContent) int {
+    if result.ContentBlocks == nil {
+        return len(result.Content)
+    }
+    size := 0
+    for _, block := range result.ContentBlocks {
+        size += len(block.Text)
+        if block.Source != nil {
+            size += len(block.Source.Data) + len(block.Source.URL)
+        }
+    }
+    return size
+}
@@ -0,0 +1,30 @@
+package goanthropic
+
+import (
+    "sort"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// WithSortedTools serializes tools in alphabetical order by name. By default
+// tools are sent in exactly the order the caller supplied them. Either way the
+// order is stable from request to request, which prompt caching depends on.
+func WithSortedTools() ClientOption {
+    return func(c *AnthropicClient) {
+        c.sortTools = true
+    }
+}
+
+// orderedTools returns the tools in the order they should be serialized
+func (c *AnthropicClient) orderedTools(tools []types.Tool) []types.Tool {
+    if !c.sortTools || len(tools) < 2 {
+        return tools
+    }
+
+    sorted := make([]types.Tool, len(tools))
+    copy(sorted, tools)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        return sorted[i].Name < sorted[j].Name
+    })
+    return sorted
+}
@@ -0,0 +1,45 @@
+package goanthropic_test
+
+import (
+    "context"
+    "strings"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+func TestToolOrderIsStable(t *testing.T) {
+    tests := []struct {
+        name string
+        opts []goanthropic.ClientOption
+        want []string
+    }{
+        {"caller order", nil, []string{"search", "calculate", "fetch"}},
+        {"sorted", []goanthropic.ClientOption{goanthropic.WithSortedTools()}, []string{"calculate", "fetch", "search"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            srv := anthropictest.NewServer(anthropictest.TextResponse("one"), anthropictest.TextResponse("two"))
+            defer srv.Close()
+            client := srv.Client(tt.opts...)
+
+            handlers := []types.ToolHandler{textTool("search", ""), textTool("calculate", ""), textTool("fetch", "")}
+            for i := 0; i < 2; i++ {
+                params := goanthropic.NewToolParams(handlers...)
+                if _, err := client.ChatWithTools(context.Background(), "Hi", &params, handlers); err != nil {
+                    t.Fatalf("ChatWithTools: %v", err)
+                }
+                req, _ := srv.LastRequest()
+                got := make([]string, len(req.Tools))
+                for j, tool := range req.Tools {
+                    got[j] = tool.Name
+                }
+                if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+                    t.Errorf("request %d tools = %v, want %v", i+1, got, tt.want)
+                }
+            }
+        })
+    }
+}
@@ -0,0 +1,234 @@
+package goanthropic
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "time"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// NewToolParams returns MessageParams that offer the tools of the given
+// handlers with an auto tool choice, ready to pass to ChatWithTools or
+// WithDefaultParams. Adding a tool only requires adding its handler.
+func NewToolParams(handlers ...types.ToolHandler) types.MessageParams {
+    tools := make([]types.Tool, 0, len(handlers))
+    for _, handler := range handlers {
+        tools = append(tools, handler.GetTool())
+    }
+    return types.MessageParams{
+        Tools:      tools,
+        ToolChoice: &types.ToolChoice{Type: types.ToolChoiceAuto},
+    }
+}
+
+// toolOutcome is the result of running one tool call
+type toolOutcome struct {
+    result   string
+    blocks   []types.MessageContent
+    err      error
+    duration time.Duration
+}
+
+// WithConcurrentTools runs the tool calls of a single model turn in parallel,
+// at most maxParallel at a time. Results are still sent back in the order the
+// model requested them. Handlers must be safe for concurrent use.
+func WithConcurrentTools(maxParallel int) ClientOption {
+    return func(c *AnthropicClient) {
+        if maxParallel > 0 {
+            c.toolParallelism = maxParallel
+        }
+    }
+}
+
+// WithSchemaValidation checks each tool call's input against the tool's
+// InputSchema before the handler runs. Invalid input is not passed to the
+// handler; the model receives an error tool_result describing the problem so
+// it can correct the call.
+func WithSchemaValidation(enabled bool) ClientOption {
+    return func(c *AnthropicClient) {
+        c.schemaValidation = enabled
+    }
+}
+
+// executeTools runs calls with their registered handlers and returns the
+// outcomes in call order
+func (c *AnthropicClient) executeTools(ctx context.Context, registry map[string]types.ToolHandler, calls []types.ToolUse) []toolOutcome {
+    run := func(call types.ToolUse) toolOutcome {
+        handler := registry[call.Name]
+        if c.schemaValidation {
+            if err := ValidateToolInput(handler.GetTool(), call.Input); err != nil {
+                c.logMessage("Rejected input for tool %s: %v", call.Name, err)
+                return toolOutcome{err: fmt.Errorf("invalid input for tool %s: %w", call.Name, err)}
+            }
+        }
+        return c.runTool(ctx, handler, call)
+    }
+
+    outcomes := make([]toolOutcome, len(calls))
+    if c.toolParallelism <= 1 || len(calls) == 1 {
+        for i, call := range calls {
+            outcomes[i] = run(call)
+        }
+        return outcomes
+    }
+
+    sem := make(chan struct{}, c.toolParallelism)
+    var wg sync.WaitGroup
+    for i, call := range calls {
+        wg.Add(1)
+        sem <- struct{}{}
+        go func(i int, call types.ToolUse) {
+            defer wg.Done()
+            defer func() { <-sem }()
+            outcomes[i] = run(call)
+        }(i, call)
+    }
+    wg.Wait()
+    return outcomes
+}
+
+// runTool executes a single tool call, converting a handler panic into an
+// error. Handlers implementing types.ToolResultHandler may return blocks.
+func (c *AnthropicClient) runTool(ctx context.Context, handler types.ToolHandler, call types.ToolUse) (outcome toolOutcome) {
+    start := time.Now()
+    defer func() {
+        if r := recover(); r != nil {
+            c.logError("Tool %s panicked: %v", call.Name, r)
+            outcome = toolOutcome{err: fmt.Errorf("tool %s panicked: %v", call.Name, r)}
+        }
+        outcome.duration = time.Since(start)
+    }()
+
+    if rich, ok := handler.(types.ToolResultHandler); ok {
+        result, err := rich.ExecuteResult(ctx, call.Input)
+        if err == nil {
+            err = validateToolResultBlocks(result.Blocks)
+        }
+        return toolOutcome{result: result.Text(), blocks: result.Blocks, err: err}
+    }
+
+    result, err := handler.Execute(ctx, call.Input)
+    return toolOutcome{result: result, err: err}
+}
+
+// validateToolResultBlocks checks that a tool result holds only text and valid images
+func validateToolResultBlocks(blocks []types.MessageContent) error {
+    for i, block := range blocks {
+        switch block.Type {
+        case types.ContentTypeText:
+        case types.ContentTypeImage:
+            if block.Source == nil {
+                return fmt.Errorf("tool result block %d: image has no source", i)
+            }
+            if err := validateImage(*block.Source); err != nil {
+                return fmt.Errorf("tool result block %d: %w", i, err)
+            }
+        default:
+            return fmt.Errorf("tool result block %d: unsupported type %q", i, block.Type)
+        }
+    }
+    return nil
+}
+
+// answerPendingToolUses stores an error tool_result for every tool call in the
+// last assistant turn when the conversation ends with that turn, so a tool
+// loop that stopped early leaves a history the API accepts
+func (c *AnthropicClient) answerPendingToolUses(cause error) {
+    c.mu.Lock()
+    defer c.unlock()
+
+    last := len(c.conversation) - 1
+    if last < 0 || c.conversation[last].Role != types.RoleAssistant {
+        return
+    }
+    var results []types.MessageContent
+    for _, block := range c.conversation[last].Content {
+        if block.Type == types.ContentTypeToolUse {
+            results = append(results, c.newToolResult(block.ID, fmt.Sprintf("Tool call not completed: %v", cause), true))
+        }
+    }
+    if len(results) > 0 {
+        c.logMessage("Answering %d unfinished tool calls after error: %v", len(results), cause)
+        c.appendMessage(types.RoleUser, results)
+    }
+}
+
+// WithToolObserver registers a callback that is told about every tool call
+// made by ChatWithTools, with its input, result or error and duration. It is
+// called synchronously from the chat loop once the turn's tools have run, so
+// a slow observer slows the chat; hand work off to another goroutine if needed.
+func WithToolObserver(observer func(types.ToolEvent)) ClientOption {
+    return func(c *AnthropicClient) {
+        c.toolObserver = observer
+    }
+}
+
+// notifyTool reports a completed tool call to the tool observer, if any
+func (c *AnthropicClient) notifyTool(event types.ToolEvent) {
+    if c.toolObserver == nil {
+        return
+    }
+    c.toolObserver(event)
+}
+
+// callTools runs the tool calls of one model turn and records them for the
+// tool observer, result metrics, LastToolInteractions and LastToolTrace. usage
+// is that of the response that requested the calls. It returns the
+// tool_result blocks to send back, in call order, and an event per call.
+func (c *AnthropicClient) callTools(ctx context.Context, registry map[string]types.ToolHandler, calls []types.ToolUse, iteration int, usage types.Usage) ([]types.MessageContent, []types.ToolEvent) {
+    outcomes := c.executeTools(ctx, registry, calls)
+    results := make([]types.MessageContent, 0, len(calls))
+    events := make([]types.ToolEvent, 0, len(calls))
+    interaction := types.ToolInteraction{Iteration: iteration, Usage: usage}
+    for i, call := range calls {
+        result, err := outcomes[i].result, outcomes[i].err
+        if err != nil {
+            result = fmt.Sprintf("Error executing tool: %v", err)
+        }
+        event := types.ToolEvent{
+            Name:      call.Name,
+            ToolUseID: call.ID,
+            Input:     call.Input,
+            Result:    result,
+            Err:       err,
+            Duration:  outcomes[i].duration,
+        }
+        c.notifyTool(event)
+        events = append(events, event)
+
+        record := types.ToolCallRecord{ToolUse: call, Result: result}
+        if err != nil {
+            record.Error = err.Error()
+        }
+        interaction.Calls = append(interaction.Calls, record)
+
+        content := c.newToolResult(call.ID, result, err != nil)
+        if err == nil && len(outcomes[i].blocks) > 0 {
+            content.Content = ""
+            content.ContentBlocks = outcomes[i].blocks
+        }
+        c.recordToolResultSize(call, content)
+        results = append(results, content)
+    }
+    root := c.root()
+    c.mu.Lock()
+    root.lastInteractions = append(root.lastInteractions, interaction)
+    root.lastToolTrace = append(root.lastToolTrace, events...)
+    c.mu.Unlock()
+    return results, events
+}
+
+// registerHandlers indexes handlers by tool name, rejecting duplicate names
+func registerHandlers(handlers []types.ToolHandler) (map[string]types.ToolHandler, error) {
+    registry := make(map[string]types.ToolHandler, len(handlers))
+    for _, handler := range handlers {
+        name := handler.GetTool().Name
+        if _, ok := registry[name]; ok {
+            return nil, fmt.Errorf("duplicate handler for tool: %s", name)
+        }
+        registry[name] = handler
+    }
+    return registry, nil
+}
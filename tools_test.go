@@ -0,0 +1,461 @@
+package goanthropic_test
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+    "sync"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// textHandler is a tool that always returns the same result
+type textHandler struct {
+    name, result string
+}
+
+func (h textHandler) GetTool() types.Tool {
+    return types.Tool{Name: h.name, Description: "Test tool " + h.name, InputSchema: types.InputSchema{Type: "object"}}
+}
+
+func (h textHandler) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+    return h.result, nil
+}
+
+// textTool returns a handler for the named tool that always returns result
+func textTool(name, result string) types.ToolHandler {
+    return textHandler{name: name, result: result}
+}
+
+// warningRecorder collects the warnings passed to WithWarningHandler
+type warningRecorder struct {
+    mu       sync.Mutex
+    warnings []string
+}
+
+func (r *warningRecorder) handle(warning string) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    r.warnings = append(r.warnings, warning)
+}
+
+func (r *warningRecorder) all() []string {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    return append([]string(nil), r.warnings...)
+}
+
+func TestToolResultSizeWarning(t *testing.T) {
+    srv := anthropictest.NewServer(
+        anthropictest.ToolUseResponse("toolu_1", "dump", map[string]string{}),
+        anthropictest.TextResponse("done"),
+    )
+    defer srv.Close()
+    warnings := &warningRecorder{}
+    client := srv.Client(
+        goanthropic.WithToolResultWarnBytes(100),
+        goanthropic.WithWarningHandler(warnings.handle),
+    )
+
+    handlers := []types.ToolHandler{textTool("dump", strings.Repeat("x", 500))}
+    params := goanthropic.NewToolParams(handlers...)
+    if _, err := client.ChatWithTools(context.Background(), "Dump it", &params, handlers); err != nil {
+        t.Fatalf("ChatWithTools: %v", err)
+    }
+
+    got := warnings.all()
+    if len(got) != 1 || !strings.Contains(got[0], `"dump"`) || !strings.Contains(got[0], "500 bytes") {
+        t.Errorf("warnings = %q, want one naming the tool and its size", got)
+    }
+    metrics := client.ToolResultMetrics()
+    if len(metrics) != 1 || metrics[0].ToolName != "dump" || metrics[0].Bytes != 500 {
+        t.Errorf("metrics = %+v", metrics)
+    }
+}
+
+func TestToolResultSizeCountsImages(t *testing.T) {
+    srv := anthropictest.NewServer(
+        anthropictest.ToolUseResponse("toolu_1", "chart", map[string]string{}),
+        anthropictest.TextResponse("done"),
+    )
+    defer srv.Close()
+    warnings := &warningRecorder{}
+    client := srv.Client(
+        goanthropic.WithModel("claude-3-5-sonnet-20241022"),
+        goanthropic.WithToolResultWarnBytes(1000),
+        goanthropic.WithWarningHandler(warnings.handle),
+    )
+
+    image := types.Base64Image(types.MediaTypePNG, strings.Repeat("A", 4000))
+    handlers := []types.ToolHandler{types.ToolResultHandlerFunc{
+        Tool: types.Tool{Name: "chart", InputSchema: types.InputSchema{Type: "object"}},
+        Func: func(ctx context.Context, input json.RawMessage) (types.ToolResult, error) {
+            return types.ToolResult{Blocks: []types.MessageContent{
+                {Type: types.ContentTypeText, Text: "chart"},
+                {Type: types.ContentTypeImage, Source: &image},
+            }}, nil
+        },
+    }}
+    if _, err := client.ChatWithTools(context.Background(), "Draw it", nil, handlers); err != nil {
+        t.Fatalf("ChatWithTools: %v", err)
+    }
+
+    if metrics := client.ToolResultMetrics(); len(metrics) != 1 || metrics[0].Bytes != 4005 {
+        t.Errorf("metrics = %+v, want the text and image data counted", metrics)
+    }
+    if got := warnings.all(); len(got) != 1 {
+        t.Errorf("warnings = %q, want one for the image result", got)
+    }
+}
+
+func TestLastToolInteractionsTwoTools(t *testing.T) {
+    twoCalls := anthropictest.ToolUseResponse("toolu_1", "weather", map[string]string{"city": "Paris"})
+    twoCalls.Content = append(twoCalls.Content, types.MessageContent{
+        Type:  types.ContentTypeToolUse,
+        ID:    "toolu_2",
+        Name:  "time",
+        Input: json.RawMessage(`{"city":"Paris"}`),
+    })
+    srv := anthropictest.NewServer(twoCalls, anthropictest.TextResponse("Sunny at noon"))
+    defer srv.Close()
+    client := srv.Client()
+
+    handlers := []types.ToolHandler{textTool("weather", "sunny"), textTool("time", "12:00")}
+    params := goanthropic.NewToolParams(handlers...)
+    if _, err := client.ChatWithTools(context.Background(), "Weather and time in Paris?", &params, handlers); err != nil {
+        t.Fatalf("ChatWithTools: %v", err)
+    }
+
+    interactions := client.LastToolInteractions()
+    if len(interactions) != 1 {
+        t.Fatalf("got %d interactions, want 1", len(interactions))
+    }
+    calls := interactions[0].Calls
+    if len(calls) != 2 {
+        t.Fatalf("got %d calls, want 2", len(calls))
+    }
+    if calls[0].ToolUse.Name != "weather" || calls[0].Result != "sunny" || calls[1].ToolUse.Name != "time" || calls[1].Result != "12:00" {
+        t.Errorf("calls = %+v", calls)
+    }
+    if trace := client.LastToolTrace(); len(trace) != 2 {
+        t.Errorf("trace has %d events, want 2", len(trace))
+    }
+}
+
+func TestAutoToolChoiceNoneOnFinalAnswer(t *testing.T) {
+    search := func(id string) types.AnthropicResponse {
+        return anthropictest.ToolUseResponse(id, "search", map[string]string{"q": "go"})
+    }
+    tests := []struct {
+        lead      int
+        responses []types.AnthropicResponse
+        want      []string
+    }{
+        {
+            lead:      0,
+            responses: []types.AnthropicResponse{search("toolu_1"), search("toolu_2"), anthropictest.TextResponse("Done")},
+            want:      []string{types.ToolChoiceAuto, types.ToolChoiceAuto, types.ToolChoiceNone},
+        },
+        {
+            lead:      1,
+            responses: []types.AnthropicResponse{search("toolu_1"), anthropictest.TextResponse("Done")},
+            want:      []string{types.ToolChoiceAuto, types.ToolChoiceNone},
+        },
+    }
+    for _, tt := range tests {
+        srv := anthropictest.NewServer(tt.responses...)
+        client := srv.Client(
+            goanthropic.WithModel("claude-3-5-sonnet-20241022"),
+            goanthropic.WithMaxToolIterations(3),
+            goanthropic.WithAutoToolChoiceNoneOnFinalAnswer(tt.lead),
+        )
+
+        handlers := []types.ToolHandler{textTool("search", "results")}
+        params := goanthropic.NewToolParams(handlers...)
+        if _, err := client.ChatWithTools(context.Background(), "Search", &params, handlers); err != nil {
+            t.Fatalf("lead %d: ChatWithTools: %v", tt.lead, err)
+        }
+
+        requests := srv.Requests()
+        if len(requests) != len(tt.want) {
+            t.Fatalf("lead %d: sent %d requests, want %d", tt.lead, len(requests), len(tt.want))
+        }
+        for i, req := range requests {
+            if req.ToolChoice == nil || req.ToolChoice.Type != tt.want[i] {
+                t.Errorf("lead %d: request %d tool_choice = %+v, want %s", tt.lead, i, req.ToolChoice, tt.want[i])
+            }
+        }
+        srv.Close()
+    }
+}
+
+func TestToolResultContentType(t *testing.T) {
+    tests := []struct {
+        format string
+        want   string
+    }{
+        {types.ToolResultFormatString, `"sunny"`},
+        {types.ToolResultFormatBlocks, `[{"type":"text","text":"sunny"}]`},
+    }
+    for _, tt := range tests {
+        srv := anthropictest.NewServer(
+            anthropictest.ToolUseResponse("toolu_1", "weather", map[string]string{"city": "Paris"}),
+            anthropictest.TextResponse("Sunny"),
+        )
+        var bodies [][]byte
+        client := srv.Client(
+            goanthropic.WithToolResultContentType(tt.format),
+            goanthropic.WithRequestSigner(func(body []byte, headers http.Header) {
+                bodies = append(bodies, append([]byte(nil), body...))
+            }),
+        )
+
+        handlers := []types.ToolHandler{textTool("weather", "sunny")}
+        params := goanthropic.NewToolParams(handlers...)
+        if _, err := client.ChatWithTools(context.Background(), "Weather?", &params, handlers); err != nil {
+            t.Fatalf("%s: ChatWithTools: %v", tt.format, err)
+        }
+
+        var sent struct {
+            Messages []struct {
+                Content []struct {
+                    Type    string          `json:"type"`
+                    Content json.RawMessage `json:"content"`
+                } `json:"content"`
+            } `json:"messages"`
+        }
+        if err := json.Unmarshal(bodies[len(bodies)-1], &sent); err != nil {
+            t.Fatalf("%s: decoding request: %v", tt.format, err)
+        }
+        result := sent.Messages[len(sent.Messages)-1].Content[0]
+        if result.Type != types.ContentTypeToolResult || string(result.Content) != tt.want {
+            t.Errorf("%s: tool_result content = %s, want %s", tt.format, result.Content, tt.want)
+        }
+        srv.Close()
+    }
+}
+
+func TestRedactedThinkingRoundTrip(t *testing.T) {
+    redacted := types.MessageContent{Type: types.ContentTypeRedactedThinking, Data: "EmwKAhgBEgy3va3pzix/LafPsn4aDFIT2Xlxh0L5L8rLVyIwxtE3rAFBa8cr3qpP"}
+    first := anthropictest.ToolUseResponse("toolu_1", "weather", map[string]string{"city": "Paris"})
+    first.Content = append([]types.MessageContent{redacted}, first.Content...)
+    srv := anthropictest.NewServer(first, anthropictest.TextResponse("Sunny"))
+    defer srv.Close()
+    client := srv.Client()
+
+    handlers := []types.ToolHandler{textTool("weather", "sunny")}
+    params := goanthropic.NewToolParams(handlers...)
+    if _, err := client.ChatWithTools(context.Background(), "Weather?", &params, handlers); err != nil {
+        t.Fatalf("ChatWithTools: %v", err)
+    }
+
+    req, _ := srv.LastRequest()
+    assistant := req.Messages[1]
+    if assistant.Role != types.RoleAssistant || len(assistant.Content) != 2 {
+        t.Fatalf("assistant turn sent as %+v", assistant)
+    }
+    if got := assistant.Content[0]; got.Type != redacted.Type || got.Data != redacted.Data {
+        t.Errorf("first block sent as %+v, want the redacted block unchanged", got)
+    }
+
+    // Export and import keep the block as well
+    data, err := client.ExportConversation()
+    if err != nil {
+        t.Fatalf("ExportConversation: %v", err)
+    }
+    restored := goanthropic.NewClient("test-key")
+    if err := restored.ImportConversation(data); err != nil {
+        t.Fatalf("ImportConversation: %v", err)
+    }
+    if got := restored.GetConversation()[1].Content[0]; got.Type != redacted.Type || got.Data != redacted.Data {
+        t.Errorf("imported block = %+v, want the redacted block unchanged", got)
+    }
+}
+
+func TestNoneToolChoice(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.TextResponse("I would search first."))
+    defer srv.Close()
+    client := srv.Client()
+
+    // Tools are visible but no handlers are needed when none can be called
+    params := goanthropic.NewToolParams(textTool("search", ""))
+    params.ToolChoice = types.NoneToolChoice()
+    resp, err := client.ChatWithTools(context.Background(), "Plan your approach", &params, nil)
+    if err != nil {
+        t.Fatalf("ChatWithTools: %v", err)
+    }
+    if resp.Text() != "I would search first." {
+        t.Errorf("reply = %q", resp.Text())
+    }
+    requests := srv.Requests()
+    if len(requests) != 1 {
+        t.Fatalf("sent %d requests, want 1", len(requests))
+    }
+    if choice := requests[0].ToolChoice; choice == nil || choice.Type != types.ToolChoiceNone || len(requests[0].Tools) != 1 {
+        t.Errorf("sent tool_choice %+v with %d tools, want none with the tool visible", choice, len(requests[0].Tools))
+    }
+}
+
+func TestNoneToolChoiceRejectsToolUse(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.ToolUseResponse("toolu_1", "search", map[string]string{"q": "go"}))
+    defer srv.Close()
+    client := srv.Client()
+
+    handlers := []types.ToolHandler{textTool("search", "results")}
+    params := goanthropic.NewToolParams(handlers...)
+    params.ToolChoice = types.NoneToolChoice()
+    _, err := client.ChatWithTools(context.Background(), "Plan your approach", &params, handlers)
+    if err == nil || !strings.Contains(err.Error(), `tool_choice is "none"`) {
+        t.Fatalf("err = %v, want an unexpected tool_use error", err)
+    }
+}
+
+func TestNewToolParams(t *testing.T) {
+    params := goanthropic.NewToolParams(textTool("search", ""), textTool("fetch", ""))
+    if len(params.Tools) != 2 || params.Tools[0].Name != "search" || params.Tools[1].Name != "fetch" {
+        t.Errorf("tools = %+v, want search and fetch in order", params.Tools)
+    }
+    if params.Tools[0].Description != "Test tool search" || params.Tools[0].InputSchema.Type != "object" {
+        t.Errorf("tool definition not copied from the handler: %+v", params.Tools[0])
+    }
+    if params.ToolChoice == nil || params.ToolChoice.Type != types.ToolChoiceAuto {
+        t.Errorf("tool choice = %+v, want auto", params.ToolChoice)
+    }
+    if params.Model != "" || params.MaxTokens != 0 {
+        t.Errorf("params set model %q and max tokens %d, want the client defaults left alone", params.Model, params.MaxTokens)
+    }
+
+    if empty := goanthropic.NewToolParams(); len(empty.Tools) != 0 {
+        t.Errorf("no handlers gave tools %+v", empty.Tools)
+    }
+}
+
+func TestLongToolSessionIsTrimmed(t *testing.T) {
+    const iterations = 30
+    const maxLength = 6
+    responses := make([]types.AnthropicResponse, 0, iterations+1)
+    for i := 0; i < iterations; i++ {
+        responses = append(responses, anthropictest.ToolUseResponse(fmt.Sprintf("toolu_%d", i), "search", map[string]int{"page": i}))
+    }
+    responses = append(responses, anthropictest.TextResponse("Done"))
+    srv := anthropictest.NewServer(responses...)
+    defer srv.Close()
+    client := srv.Client(
+        goanthropic.WithModel("claude-3-5-sonnet-20241022"),
+        goanthropic.WithMaxToolIterations(iterations+1),
+        goanthropic.WithMaxConversationLength(maxLength),
+    )
+
+    handlers := []types.ToolHandler{textTool("search", "results")}
+    params := goanthropic.NewToolParams(handlers...)
+    if _, err := client.ChatWithTools(context.Background(), "Read every page", &params, handlers); err != nil {
+        t.Fatalf("ChatWithTools: %v", err)
+    }
+
+    requests := srv.Requests()
+    if len(requests) != iterations+1 {
+        t.Fatalf("sent %d requests, want %d", len(requests), iterations+1)
+    }
+    for i, req := range requests {
+        if len(req.Messages) > maxLength {
+            t.Errorf("request %d sent %d messages, want at most %d", i, len(req.Messages), maxLength)
+        }
+        if req.Messages[0].Role != types.RoleUser {
+            t.Errorf("request %d starts with %s", i, req.Messages[0].Role)
+        }
+        used := make(map[string]bool)
+        for _, msg := range req.Messages {
+            for _, block := range msg.Content {
+                if block.Type == types.ContentTypeToolUse {
+                    used[block.ID] = true
+                }
+                if block.Type == types.ContentTypeToolResult && !used[block.ToolUseID] {
+                    t.Errorf("request %d has an orphaned tool_result for %s", i, block.ToolUseID)
+                }
+            }
+        }
+    }
+    if got := len(client.GetConversation()); got > maxLength {
+        t.Errorf("conversation holds %d messages, want at most %d", got, maxLength)
+    }
+}
+
+// assertValidHistory fails the test unless every tool_use in messages is
+// answered by the next message and every tool_result answers the one before
+func assertValidHistory(t *testing.T, messages []types.Message) {
+    t.Helper()
+    for i, msg := range messages {
+        var pending map[string]bool
+        if i > 0 {
+            pending = make(map[string]bool)
+            for _, block := range messages[i-1].Content {
+                if block.Type == types.ContentTypeToolUse {
+                    pending[block.ID] = true
+                }
+            }
+        }
+        for _, block := range msg.Content {
+            if block.Type == types.ContentTypeToolResult {
+                if !pending[block.ToolUseID] {
+                    t.Errorf("message %d answers %s, which the previous message did not call", i, block.ToolUseID)
+                }
+                delete(pending, block.ToolUseID)
+            }
+        }
+        if len(pending) > 0 && msg.Role == types.RoleUser {
+            t.Errorf("message %d leaves tool calls %v unanswered", i, pending)
+        }
+        if i == len(messages)-1 && hasToolUse(msg) {
+            t.Errorf("history ends with an unanswered tool_use")
+        }
+    }
+}
+
+func hasToolUse(msg types.Message) bool {
+    for _, block := range msg.Content {
+        if block.Type == types.ContentTypeToolUse {
+            return true
+        }
+    }
+    return false
+}
+
+func TestCancelAfterToolUseKeepsHistoryValid(t *testing.T) {
+    srv := anthropictest.NewServer(anthropictest.ToolUseResponse("toolu_1", "slow", map[string]string{"q": "go"}))
+    defer srv.Close()
+    client := srv.Client(goanthropic.WithModel("claude-3-5-sonnet-20241022"))
+
+    ctx, cancel := context.WithCancel(context.Background())
+    slow := types.ToolHandlerFunc{
+        Tool: types.Tool{Name: "slow", InputSchema: types.InputSchema{Type: "object"}},
+        Func: func(ctx context.Context, input json.RawMessage) (string, error) {
+            // The user stops the run while the tool is working
+            cancel()
+            <-ctx.Done()
+            return "", ctx.Err()
+        },
+    }
+    handlers := []types.ToolHandler{slow}
+    params := goanthropic.NewToolParams(handlers...)
+    if _, err := client.ChatWithTools(ctx, "Search slowly", &params, handlers); !errors.Is(err, context.Canceled) {
+        t.Fatalf("err = %v, want context.Canceled", err)
+    }
+    assertValidHistory(t, client.GetConversation())
+
+    // The same conversation can be continued
+    srv.Enqueue(anthropictest.TextResponse("Continuing"))
+    if _, err := client.ChatMe(context.Background(), "Never mind, just answer", nil); err != nil {
+        t.Fatalf("ChatMe after cancel: %v", err)
+    }
+    req, _ := srv.LastRequest()
+    assertValidHistory(t, req.Messages)
+    if req.Messages[0].Role != types.RoleUser {
+        t.Errorf("continued request starts with %s", req.Messages[0].Role)
+    }
+}
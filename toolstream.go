@@ -0,0 +1,174 @@
+package goanthropic
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+
+    "github.com/rdhillbb/goanthropic/types"
+)
+
+// ChatWithToolsStream is the streaming form of ChatWithTools. Text deltas are
+// delivered as they arrive and each tool call once its input is complete;
+// when the model stops to call tools the handlers run, a StreamEventToolResult
+// is sent for each call and the continuation is streamed on the same channel.
+// A single StreamEventMessageStop carrying the final response ends the stream.
+//
+// The stored conversation ends up as it would with ChatWithTools. As with
+// ChatStream, response validation and minimum length retries are not applied.
+// A failure after the first request has been accepted is delivered as a
+// StreamEventError, and unanswered tool calls receive error tool_results.
+func (c *AnthropicClient) ChatWithToolsStream(ctx context.Context, message string, params *types.MessageParams, handlers []types.ToolHandler) (<-chan types.StreamEvent, error) {
+    if c.stateless {
+        return c.detached(nil).ChatWithToolsStream(ctx, message, params, handlers)
+    }
+    ctx, cancel := c.withDefaultDeadline(ctx)
+
+    finalParams := c.mergeParams(params)
+    limit := c.conversationLimit(finalParams)
+    if err := validateToolParams(&finalParams); err != nil {
+        cancel()
+        return nil, fmt.Errorf("invalid parameters: %w", err)
+    }
+    registry, err := registerHandlers(handlers)
+    if err != nil {
+        cancel()
+        return nil, fmt.Errorf("invalid handlers: %w", err)
+    }
+
+    c.addMessageToConversation(types.RoleUser, []types.MessageContent{{
+        Type: types.ContentTypeText,
+        Text: message,
+    }})
+    c.trimConversationHistory(limit)
+    c.resetToolRecords()
+
+    loop := &toolStream{c: c, params: finalParams, limit: limit, registry: registry, loops: c.newToolLoopDetector()}
+    resp, err := c.openStream(ctx, loop.request())
+    if err != nil {
+        cancel()
+        c.recordError(err)
+        return nil, err
+    }
+
+    events := make(chan types.StreamEvent)
+    go func() {
+        defer cancel()
+        defer close(events)
+
+        emit := streamEmitter(ctx, events)
+        response, err := loop.run(ctx, resp, emit)
+        if err != nil {
+            c.answerPendingToolUses(err)
+            c.failStream(ctx, err, emit)
+            return
+        }
+        emit(types.StreamEvent{Type: types.StreamEventMessageStop, Response: response})
+    }()
+    return events, nil
+}
+
+// toolStream holds the state of a streamed tool loop
+type toolStream struct {
+    c          *AnthropicClient
+    params     types.MessageParams
+    limit      int
+    registry   map[string]types.ToolHandler
+    loops      *toolLoopDetector
+    iterations int
+}
+
+// toolChoice returns the tool choice for the current iteration
+func (s *toolStream) toolChoice() *types.ToolChoice {
+    c := s.c
+    if c.forceFinalAnswer && s.iterations >= c.maxToolIterations-1-c.finalAnswerLead {
+        return types.NoneToolChoice()
+    }
+    return s.params.ToolChoice
+}
+
+// request builds the streaming request for the current iteration
+func (s *toolStream) request() types.Request {
+    return types.Request{
+        Model:         s.params.Model,
+        System:        s.params.System,
+        SystemBlocks:  s.params.SystemBlocks,
+        Messages:      s.c.conversationSnapshot(),
+        MaxTokens:     s.params.MaxTokens,
+        Temperature:   s.params.Temperature,
+        TopP:          s.params.TopP,
+        TopK:          s.params.TopK,
+        StopSequences: s.params.StopSequences,
+        Metadata:      s.params.Metadata,
+        Tools:         s.c.orderedTools(s.params.Tools),
+        ToolChoice:    s.toolChoice(),
+        Thinking:      s.params.Thinking,
+        Stream:        true,
+    }
+}
+
+// run streams responses and executes the tools they call until the model
+// answers without tools. resp is the already opened first stream.
+func (s *toolStream) run(ctx context.Context, resp *http.Response, emit func(types.StreamEvent) bool) (*types.AnthropicResponse, error) {
+    c := s.c
+    for {
+        if resp == nil {
+            if s.iterations >= c.maxToolIterations {
+                return nil, &MaxIterationsError{Limit: c.maxToolIterations, Conversation: c.GetConversation()}
+            }
+            var err error
+            if resp, err = c.openStream(ctx, s.request()); err != nil {
+                return nil, err
+            }
+        }
+        toolChoice := s.toolChoice()
+        response, err := c.readStream(ctx, resp, emit)
+        resp.Body.Close()
+        resp = nil
+        if err != nil {
+            return nil, err
+        }
+
+        if content := c.assistantContent(response.Content); len(content) > 0 {
+            c.addMessageToConversation(types.RoleAssistant, content)
+            c.trimConversationHistory(s.limit)
+        }
+        if response.StopReason != types.StopReasonToolUse {
+            return response, nil
+        }
+        if toolChoice != nil && toolChoice.Type == types.ToolChoiceNone {
+            return nil, fmt.Errorf("received tool_use stop reason while tool_choice is %q", types.ToolChoiceNone)
+        }
+
+        toolCalls := extractToolCalls(response)
+        if len(toolCalls) == 0 {
+            return nil, fmt.Errorf("received tool_use stop reason but no valid tool calls found")
+        }
+        for _, call := range toolCalls {
+            if _, ok := s.registry[call.Name]; !ok {
+                return nil, fmt.Errorf("no handler for tool: %s", call.Name)
+            }
+        }
+
+        repeated, err := s.loops.observe(toolCalls)
+        if err != nil {
+            return nil, err
+        }
+
+        resultContents, toolEvents := c.callTools(ctx, s.registry, toolCalls, s.iterations, response.Usage)
+        noteRepeats(resultContents, repeated)
+        c.addMessageToConversation(types.RoleUser, resultContents)
+        c.trimConversationHistory(s.limit)
+        for i := range toolEvents {
+            if !emit(types.StreamEvent{Type: types.StreamEventToolResult, Tool: &toolEvents[i]}) {
+                return nil, ctx.Err()
+            }
+        }
+
+        // Release a forced choice after it has been honoured, keeping the parallel tool use setting
+        if choice := s.params.ToolChoice; choice != nil && (choice.Type == types.ToolChoiceAny || choice.Type == types.ToolChoiceTool) {
+            s.params.ToolChoice = &types.ToolChoice{Type: types.ToolChoiceAuto, DisableParallelToolUse: choice.DisableParallelToolUse}
+        }
+        s.iterations++
+    }
+}
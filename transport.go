@@ -0,0 +1,182 @@
+package goanthropic
+
+import (
+    "crypto/tls"
+    "fmt"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// WithBaseURL sends all API requests to baseURL instead of
+// https://api.anthropic.com, for gateways, proxies and local mocks. The
+// messages, count_tokens and batches paths are appended to it, so a base with
+// a path prefix such as https://gateway.internal/anthropic works too. An
+// invalid URL is reported by every call made with the client.
+func WithBaseURL(baseURL string) ClientOption {
+    return func(c *AnthropicClient) {
+        u, err := url.Parse(baseURL)
+        if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+            c.configErr = fmt.Errorf("invalid base URL %q: must be an absolute http or https URL", baseURL)
+            c.warn("%v", c.configErr)
+            return
+        }
+        c.baseURL = strings.TrimRight(baseURL, "/")
+    }
+}
+
+// endpoint returns the full URL of an API path
+func (c *AnthropicClient) endpoint(path string) string {
+    return c.baseURL + path
+}
+
+// WithForceHTTP1 disables HTTP/2 so requests always use HTTP/1.1. This works
+// around corporate proxies that mishandle HTTP/2 streams. It only affects the
+// client's default HTTP client; a client supplied with WithHTTPClient is used
+// exactly as configured.
+func WithForceHTTP1(force bool) ClientOption {
+    return func(c *AnthropicClient) {
+        c.forceHTTP1 = force
+    }
+}
+
+// WithAPIVersion sets the anthropic-version header sent with every request,
+// for pinning a newer or preview API version. An empty version keeps the
+// default of 2023-06-01.
+func WithAPIVersion(version string) ClientOption {
+    return func(c *AnthropicClient) {
+        if version = strings.TrimSpace(version); version != "" {
+            c.apiVersion = version
+        }
+    }
+}
+
+// WithUserAgent replaces the default User-Agent of goanthropic/<Version>, for
+// gateways that identify or rate limit traffic by client. An empty value keeps
+// the default.
+func WithUserAgent(userAgent string) ClientOption {
+    return func(c *AnthropicClient) {
+        if userAgent = strings.TrimSpace(userAgent); userAgent != "" {
+            c.userAgent = userAgent
+        }
+    }
+}
+
+// WithBetaFeatures opts every request into the given beta features by sending
+// them in the anthropic-beta header. Repeated use accumulates features, and
+// duplicates are sent once.
+func WithBetaFeatures(features ...string) ClientOption {
+    return func(c *AnthropicClient) {
+        c.betaFeatures = appendUnique(c.betaFeatures, features...)
+    }
+}
+
+// withBetaFeatures combines the configured beta features with those a request needs
+func (c *AnthropicClient) withBetaFeatures(betas []string) []string {
+    if len(c.betaFeatures) == 0 {
+        return betas
+    }
+    return appendUnique(append([]string(nil), c.betaFeatures...), betas...)
+}
+
+// appendUnique appends the non-empty values not already present in list
+func appendUnique(list []string, values ...string) []string {
+    for _, value := range values {
+        value = strings.TrimSpace(value)
+        if value == "" {
+            continue
+        }
+        found := false
+        for _, existing := range list {
+            found = found || existing == value
+        }
+        if !found {
+            list = append(list, value)
+        }
+    }
+    return list
+}
+
+// WithMiddleware wraps the HTTP transport used for every API request, for
+// logging, header injection or credential rotation. It may be given several
+// times; the first middleware registered is the outermost, so it sees each
+// request first and each response last. A client supplied with WithHTTPClient
+// is copied rather than modified.
+func WithMiddleware(middleware func(http.RoundTripper) http.RoundTripper) ClientOption {
+    return func(c *AnthropicClient) {
+        if middleware != nil {
+            c.middleware = append(c.middleware, middleware)
+        }
+    }
+}
+
+// applyMiddleware installs the registered middleware around the HTTP transport
+func (c *AnthropicClient) applyMiddleware() {
+    if len(c.middleware) == 0 {
+        return
+    }
+
+    transport := c.httpClient.Transport
+    if transport == nil {
+        transport = http.DefaultTransport
+    }
+    for i := len(c.middleware) - 1; i >= 0; i-- {
+        transport = c.middleware[i](transport)
+    }
+
+    client := *c.httpClient
+    client.Transport = transport
+    c.httpClient = &client
+}
+
+// defaultMaxIdleConnsPerHost is the number of idle connections the default
+// transport keeps open to the API. Go's default of two causes connection churn
+// when many requests run concurrently.
+const defaultMaxIdleConnsPerHost = 100
+
+// WithTransport sets the transport of the client's default HTTP client, for
+// tuning the connection pool of a service under load. Without it the client
+// uses a copy of http.DefaultTransport that keeps up to 100 idle connections
+// to the API. It is ignored when WithHTTPClient is used, since that client's
+// own transport is kept; with WithForceHTTP1 a copy of the transport is used
+// with HTTP/2 disabled.
+func WithTransport(transport *http.Transport) ClientOption {
+    return func(c *AnthropicClient) {
+        if transport != nil {
+            c.transport = transport
+        }
+    }
+}
+
+// defaultTransport returns the transport for the client's default HTTP client
+func (c *AnthropicClient) defaultTransport() *http.Transport {
+    transport := c.transport
+    if transport == nil {
+        transport = http.DefaultTransport.(*http.Transport).Clone()
+        transport.MaxIdleConns = defaultMaxIdleConnsPerHost
+        transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+    }
+    if c.forceHTTP1 {
+        transport = newHTTP1Transport(transport)
+    }
+    return transport
+}
+
+// newHTTP1Transport returns a copy of base that never negotiates HTTP/2
+func newHTTP1Transport(base *http.Transport) *http.Transport {
+    transport := base.Clone()
+    transport.ForceAttemptHTTP2 = false
+    // A non-nil, empty map stops the transport from upgrading TLS connections to h2
+    transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+    // A TLS config that still offers h2 would let the server pick it
+    if transport.TLSClientConfig != nil {
+        protos := make([]string, 0, len(transport.TLSClientConfig.NextProtos))
+        for _, proto := range transport.TLSClientConfig.NextProtos {
+            if proto != "h2" {
+                protos = append(protos, proto)
+            }
+        }
+        transport.TLSClientConfig.NextProtos = protos
+    }
+    return transport
+}
@@ -0,0 +1,100 @@
+package goanthropic_test
+
+import (
+    "bytes"
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/rdhillbb/goanthropic"
+    "github.com/rdhillbb/goanthropic/anthropictest"
+)
+
+func TestForceHTTP1(t *testing.T) {
+    tests := []struct {
+        force bool
+        want  int
+    }{
+        {false, 2},
+        {true, 1},
+    }
+    for _, tt := range tests {
+        var protoMajor int
+        srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            protoMajor = r.ProtoMajor
+            w.Header().Set("Content-Type", "application/json")
+            json.NewEncoder(w).Encode(anthropictest.TextResponse("Hello"))
+        }))
+        srv.EnableHTTP2 = true
+        srv.StartTLS()
+
+        // The test server's transport trusts its certificate and negotiates h2
+        transport := srv.Client().Transport.(*http.Transport)
+        client := goanthropic.NewClient("test-key",
+            goanthropic.WithBaseURL(srv.URL),
+            goanthropic.WithModel("claude-3-5-sonnet-20241022"),
+            goanthropic.WithTransport(transport),
+            goanthropic.WithForceHTTP1(tt.force),
+        )
+
+        if _, err := client.ChatMe(context.Background(), "Hi", nil); err != nil {
+            t.Fatalf("force %v: ChatMe: %v", tt.force, err)
+        }
+        if protoMajor != tt.want {
+            t.Errorf("force %v: request used HTTP/%d, want HTTP/%d", tt.force, protoMajor, tt.want)
+        }
+        srv.Close()
+    }
+}
+
+// sign returns the hex HMAC-SHA256 of body under key
+func sign(key, body []byte) string {
+    mac := hmac.New(sha256.New, key)
+    mac.Write(body)
+    return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestRequestSignerSeesSentBody(t *testing.T) {
+    key := []byte("proxy-secret")
+    var received []byte
+    var signature, apiKey string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        received, _ = io.ReadAll(r.Body)
+        signature = r.Header.Get("X-Signature")
+        apiKey = r.Header.Get("x-api-key")
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(anthropictest.TextResponse("Hello"))
+    }))
+    defer srv.Close()
+
+    var signed []byte
+    client := goanthropic.NewClient("test-key",
+        goanthropic.WithBaseURL(srv.URL),
+        goanthropic.WithRequestSigner(func(body []byte, headers http.Header) {
+            signed = append([]byte(nil), body...)
+            if headers.Get("x-api-key") == "" {
+                t.Error("signer ran before the standard headers were set")
+            }
+            headers.Set("X-Signature", sign(key, body))
+        }),
+    )
+
+    if _, err := client.ChatMe(context.Background(), "Hi", nil); err != nil {
+        t.Fatalf("ChatMe: %v", err)
+    }
+    if !bytes.Equal(signed, received) {
+        t.Errorf("signer saw %s, server received %s", signed, received)
+    }
+    if want := sign(key, received); signature != want {
+        t.Errorf("X-Signature = %q, want %q", signature, want)
+    }
+    if apiKey != "test-key" {
+        t.Errorf("x-api-key = %q", apiKey)
+    }
+}
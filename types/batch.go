@@ -0,0 +1,64 @@
+package types
+
+import "time"
+
+// Batch processing statuses and result types
+const (
+    BatchStatusInProgress = "in_progress"
+    BatchStatusCanceling  = "canceling"
+    BatchStatusEnded      = "ended"
+
+    BatchResultSucceeded = "succeeded"
+    BatchResultErrored   = "errored"
+    BatchResultCanceled  = "canceled"
+    BatchResultExpired   = "expired"
+)
+
+// BatchRequest is one entry of a message batch. CustomID identifies the entry
+// in the results and must be unique within the batch. Params.Messages holds
+// the conversation to send; other fields are merged with the client defaults.
+type BatchRequest struct {
+    CustomID string
+    Params   MessageParams
+}
+
+// Batch describes a message batch and its processing progress
+type Batch struct {
+    ID                string             `json:"id"`
+    Type              string             `json:"type"`
+    ProcessingStatus  string             `json:"processing_status"`
+    RequestCounts     BatchRequestCounts `json:"request_counts"`
+    CreatedAt         time.Time          `json:"created_at"`
+    ExpiresAt         time.Time          `json:"expires_at"`
+    EndedAt           *time.Time         `json:"ended_at"`
+    CancelInitiatedAt *time.Time         `json:"cancel_initiated_at"`
+    ResultsURL        string             `json:"results_url"`
+}
+
+// BatchRequestCounts tallies the entries of a batch by state
+type BatchRequestCounts struct {
+    Processing int `json:"processing"`
+    Succeeded  int `json:"succeeded"`
+    Errored    int `json:"errored"`
+    Canceled   int `json:"canceled"`
+    Expired    int `json:"expired"`
+}
+
+// BatchResult is the outcome of one batch entry
+type BatchResult struct {
+    CustomID string `json:"custom_id"`
+    Result   struct {
+        Type    string             `json:"type"`
+        Message *AnthropicResponse `json:"message,omitempty"`
+        Error   *BatchError        `json:"error,omitempty"`
+    } `json:"result"`
+}
+
+// BatchError describes why a batch entry failed
+type BatchError struct {
+    Type  string `json:"type"`
+    Error struct {
+        Type    string `json:"type"`
+        Message string `json:"message"`
+    } `json:"error"`
+}
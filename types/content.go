@@ -0,0 +1,97 @@
+package types
+
+import (
+    "bytes"
+    "encoding/json"
+)
+
+// MarshalJSON writes ContentBlocks as the "content" array when present, and
+// the Content string otherwise
+func (m MessageContent) MarshalJSON() ([]byte, error) {
+    type plain MessageContent
+    if m.ContentBlocks == nil {
+        return json.Marshal(plain(m))
+    }
+    return json.Marshal(struct {
+        plain
+        Content []MessageContent `json:"content"`
+    }{plain(m), m.ContentBlocks})
+}
+
+// MarshalJSON sends SystemBlocks as an array of text blocks, unless there is
+// a single block without a cache marker, which is sent as a plain string.
+// Without blocks the system prompt is sent as a cacheable text block when
+// SystemCacheControl is set, and as a plain string otherwise.
+func (r Request) MarshalJSON() ([]byte, error) {
+    type plain Request
+    var blocks []MessageContent
+    r.System, blocks = systemJSON(r.System, r.SystemBlocks, r.SystemCacheControl)
+    if blocks == nil {
+        return json.Marshal(plain(r))
+    }
+    return json.Marshal(struct {
+        plain
+        System []MessageContent `json:"system"`
+    }{plain(r), blocks})
+}
+
+// MarshalJSON sends the system prompt in the same form as Request does
+func (r CountTokensRequest) MarshalJSON() ([]byte, error) {
+    type plain CountTokensRequest
+    var blocks []MessageContent
+    r.System, blocks = systemJSON(r.System, r.SystemBlocks, r.SystemCacheControl)
+    if blocks == nil {
+        return json.Marshal(plain(r))
+    }
+    return json.Marshal(struct {
+        plain
+        System []MessageContent `json:"system"`
+    }{plain(r), blocks})
+}
+
+// systemJSON returns the system prompt either as a plain string or, when it
+// has to carry cache markers or is split, as text blocks
+func systemJSON(system string, systemBlocks []SystemBlock, cacheControl *CacheControl) (string, []MessageContent) {
+    if len(systemBlocks) == 1 && systemBlocks[0].CacheControl == nil {
+        return systemBlocks[0].Text, nil
+    }
+    var blocks []MessageContent
+    if len(systemBlocks) > 0 {
+        for _, block := range systemBlocks {
+            blocks = append(blocks, MessageContent{
+                Type:         ContentTypeText,
+                Text:         block.Text,
+                CacheControl: block.CacheControl,
+            })
+        }
+    } else if cacheControl != nil && system != "" {
+        blocks = []MessageContent{{
+            Type:         ContentTypeText,
+            Text:         system,
+            CacheControl: cacheControl,
+        }}
+    }
+    return system, blocks
+}
+
+// UnmarshalJSON accepts "content" either as a string or as an array of blocks
+func (m *MessageContent) UnmarshalJSON(data []byte) error {
+    type plain MessageContent
+    var raw struct {
+        plain
+        Content json.RawMessage `json:"content,omitempty"`
+    }
+    if err := json.Unmarshal(data, &raw); err != nil {
+        return err
+    }
+    *m = MessageContent(raw.plain)
+
+    content := bytes.TrimSpace(raw.Content)
+    if len(content) == 0 || bytes.Equal(content, []byte("null")) {
+        return nil
+    }
+    if content[0] == '[' {
+        return json.Unmarshal(content, &m.ContentBlocks)
+    }
+    return json.Unmarshal(content, &m.Content)
+}
@@ -0,0 +1,11 @@
+package types
+
+import "time"
+
+// Model describes a model available to the API key
+type Model struct {
+    ID          string    `json:"id"`
+    Type        string    `json:"type"`
+    DisplayName string    `json:"display_name"`
+    CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,100 @@
+package types
+
+import (
+    "encoding/json"
+    "strings"
+)
+
+// ResponseView is a flattened form of a response for templates and JSON APIs
+type ResponseView struct {
+    Text       string         `json:"text"`
+    ToolCalls  []ToolCallView `json:"tool_calls,omitempty"`
+    Thinking   string         `json:"thinking,omitempty"`
+    StopReason string         `json:"stop_reason"`
+    Usage      Usage          `json:"usage"`
+}
+
+// ToolCallView is a single tool call within a ResponseView
+type ToolCallView struct {
+    ID    string          `json:"id"`
+    Name  string          `json:"name"`
+    Input json.RawMessage `json:"input"`
+}
+
+// Text returns the concatenated answer text of the response, excluding thinking
+func (r *AnthropicResponse) Text() string {
+    return r.ToView().Text
+}
+
+// ToolUses returns the tool calls requested in the response, in order
+func (r *AnthropicResponse) ToolUses() []ToolUse {
+    var uses []ToolUse
+    for _, content := range r.Content {
+        if content.Type == ContentTypeToolUse {
+            uses = append(uses, ToolUse{
+                ID:    content.ID,
+                Name:  content.Name,
+                Input: content.Input,
+            })
+        }
+    }
+    return uses
+}
+
+// ToolUseCount returns the number of tool calls requested in the response
+func (r *AnthropicResponse) ToolUseCount() int {
+    count := 0
+    for _, content := range r.Content {
+        if content.Type == ContentTypeToolUse {
+            count++
+        }
+    }
+    return count
+}
+
+// WasTruncated reports whether generation stopped because it reached max_tokens
+func (r *AnthropicResponse) WasTruncated() bool {
+    return r.StopReason == StopReasonMaxTokens
+}
+
+// IsRefusal reports whether the model declined to answer
+func (r *AnthropicResponse) IsRefusal() bool {
+    return r.StopReason == StopReasonRefusal
+}
+
+// ThinkingText returns the model's extended thinking, separate from the answer text
+func (r *AnthropicResponse) ThinkingText() string {
+    return r.ToView().Thinking
+}
+
+// ToView flattens the response content blocks into a ResponseView. Text and
+// thinking blocks are concatenated in order; the response itself is not modified.
+func (r *AnthropicResponse) ToView() ResponseView {
+    var text, thinking strings.Builder
+    view := ResponseView{
+        StopReason: r.StopReason,
+        Usage:      r.Usage,
+    }
+
+    for _, content := range r.Content {
+        switch content.Type {
+        case ContentTypeText:
+            text.WriteString(content.Text)
+        case ContentTypeThinking:
+            if thinking.Len() > 0 {
+                thinking.WriteString("\n")
+            }
+            thinking.WriteString(content.Thinking)
+        case ContentTypeToolUse:
+            view.ToolCalls = append(view.ToolCalls, ToolCallView{
+                ID:    content.ID,
+                Name:  content.Name,
+                Input: content.Input,
+            })
+        }
+    }
+
+    view.Text = text.String()
+    view.Thinking = thinking.String()
+    return view
+}
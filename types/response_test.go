@@ -0,0 +1,41 @@
+package types
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+func TestToViewFlattensMixedResponse(t *testing.T) {
+    resp := &AnthropicResponse{
+        StopReason: StopReasonToolUse,
+        Usage:      Usage{InputTokens: 12, OutputTokens: 34},
+        Content: []MessageContent{
+            {Type: ContentTypeThinking, Thinking: "The user wants weather."},
+            {Type: ContentTypeText, Text: "Let me check "},
+            {Type: ContentTypeToolUse, ID: "toolu_1", Name: "weather", Input: json.RawMessage(`{"city":"Paris"}`)},
+            {Type: ContentTypeThinking, Thinking: "And the time."},
+            {Type: ContentTypeText, Text: "both."},
+            {Type: ContentTypeToolUse, ID: "toolu_2", Name: "time", Input: json.RawMessage(`{"city":"Paris"}`)},
+        },
+    }
+
+    view := resp.ToView()
+    if view.Text != "Let me check both." {
+        t.Errorf("Text = %q", view.Text)
+    }
+    if view.Thinking != "The user wants weather.\nAnd the time." {
+        t.Errorf("Thinking = %q", view.Thinking)
+    }
+    if len(view.ToolCalls) != 2 || view.ToolCalls[0].Name != "weather" || view.ToolCalls[1].ID != "toolu_2" {
+        t.Fatalf("ToolCalls = %+v", view.ToolCalls)
+    }
+    if string(view.ToolCalls[0].Input) != `{"city":"Paris"}` {
+        t.Errorf("tool input = %s", view.ToolCalls[0].Input)
+    }
+    if view.StopReason != StopReasonToolUse || view.Usage != resp.Usage {
+        t.Errorf("StopReason = %q, Usage = %+v", view.StopReason, view.Usage)
+    }
+    if len(resp.Content) != 6 {
+        t.Errorf("the response has %d blocks after ToView, want 6", len(resp.Content))
+    }
+}
@@ -0,0 +1,28 @@
+package types
+
+// Stream event types delivered by ChatStream and ChatWithToolsStream
+const (
+    StreamEventText        = "text_delta"
+    StreamEventToolUse     = "tool_use"
+    StreamEventToolResult  = "tool_result"
+    StreamEventMessageStop = "message_stop"
+    StreamEventError       = "error"
+)
+
+// StreamEvent is a single incremental update from a streamed response
+type StreamEvent struct {
+    Type string
+    // Index is the position of the content block the event belongs to
+    Index int
+    // Text holds the new text for StreamEventText
+    Text string
+    // ToolUse holds the tool call for StreamEventToolUse, which is sent once
+    // the block has finished streaming, so its Input is complete JSON
+    ToolUse *MessageContent
+    // Tool describes the finished tool call for StreamEventToolResult
+    Tool *ToolEvent
+    // Response holds the assembled response for StreamEventMessageStop
+    Response *AnthropicResponse
+    // Err holds the failure for StreamEventError
+    Err error
+}
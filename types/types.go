@@ -3,6 +3,8 @@ package types
 import (
     "context"
     "encoding/json"
+    "strings"
+    "time"
 )
 
 // Role and content type constants
@@ -11,64 +13,156 @@ const (
     RoleUser      = "user"
     RoleAssistant = "assistant"
     
-    ContentTypeText       = "text"
-    ContentTypeToolUse    = "tool_use"
-    ContentTypeToolResult = "tool_result"
-    ContentTypeThinking   = "thinking"  
+    ContentTypeText             = "text"
+    ContentTypeToolUse          = "tool_use"
+    ContentTypeToolResult       = "tool_result"
+    ContentTypeThinking         = "thinking"
+    ContentTypeRedactedThinking = "redacted_thinking"
+    ContentTypeImage            = "image"
+    ContentTypeDocument         = "document"
     
     StopReasonToolUse      = "tool_use"
     StopReasonEndTurn      = "end_turn"
     StopReasonMaxTokens    = "max_tokens"
     StopReasonStopSequence = "stop_sequence"  
+    StopReasonRefusal      = "refusal"
     
     ToolChoiceAuto = "auto"
+    ToolChoiceAny  = "any"
     ToolChoiceNone = "none"
     ToolChoiceTool = "tool"
+
+    CacheControlEphemeral = "ephemeral"
+    CacheTTL5m            = "5m"
+    CacheTTL1h            = "1h"
+
+    ToolResultFormatString = "string"
+    ToolResultFormatBlocks = "blocks"
+
+    ThinkingEnabled = "enabled"
 )
 
 // Message represents a single message in the conversation
 type Message struct {
     Role    string           `json:"role"`    
     Content []MessageContent `json:"content"` 
+
+    // ID and CreatedAt are assigned when the message is stored by the client;
+    // they are not sent to the API
+    ID        string    `json:"-"`
+    CreatedAt time.Time `json:"-"`
 }
 
-// MessageContent represents different types of content within a message
+// MessageContent represents different types of content within a message.
+// Thinking and redacted_thinking blocks returned by the API must be sent back
+// unchanged on later turns; Data carries the opaque payload of a redacted block.
 type MessageContent struct {
-    Type       string          `json:"type"`               
-    Text       string          `json:"text,omitempty"`     
-    ID         string          `json:"id,omitempty"`       
-    Name       string          `json:"name,omitempty"`     
-    Input      json.RawMessage `json:"input,omitempty"`    
-    ToolUseID  string          `json:"tool_use_id,omitempty"`  
-    Content    string          `json:"content,omitempty"`      
-    IsError    bool            `json:"is_error,omitempty"`     
+    Type         string          `json:"type"`
+    Text         string          `json:"text,omitempty"`
+    ID           string          `json:"id,omitempty"`
+    Name         string          `json:"name,omitempty"`
+    Input        json.RawMessage `json:"input,omitempty"`
+    ToolUseID    string          `json:"tool_use_id,omitempty"`
+    Content      string          `json:"content,omitempty"`
+    IsError      bool            `json:"is_error,omitempty"`
+    Thinking     string          `json:"thinking,omitempty"`
+    Signature    string          `json:"signature,omitempty"`
+    Data         string          `json:"data,omitempty"`
+    Source       *ImageSource    `json:"source,omitempty"`
+    CacheControl *CacheControl   `json:"cache_control,omitempty"`
+
+    // ContentBlocks holds tool result content sent as an array of blocks. When
+    // set it is serialized as "content" in place of the Content string.
+    ContentBlocks []MessageContent `json:"-"`
 }
 
 // Tool represents an available function that can be called
 type Tool struct {
-    Type     string   `json:"type"`
-    Function Function `json:"function"`
+    Name         string        `json:"name"`
+    Description  string        `json:"description"`
+    InputSchema  InputSchema   `json:"input_schema"`
+    CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl marks a content block or tool as the end of a cacheable prompt prefix.
+// TTL is optional and may be "5m" (the default) or "1h".
+type CacheControl struct {
+    Type string `json:"type"`
+    TTL  string `json:"ttl,omitempty"`
+}
+
+// EphemeralCache returns an ephemeral cache marker with the given TTL,
+// or the default TTL when ttl is empty
+func EphemeralCache(ttl string) *CacheControl {
+    return &CacheControl{Type: CacheControlEphemeral, TTL: ttl}
 }
 
-// Function represents the details of a callable function
-type Function struct {
-    Name        string      `json:"name"`
-    Description string      `json:"description"`
-    Parameters  Parameters  `json:"parameters"`
+// Image source types and supported image media types
+const (
+    ImageSourceBase64 = "base64"
+    ImageSourceURL    = "url"
+
+    MediaTypePNG  = "image/png"
+    MediaTypeJPEG = "image/jpeg"
+    MediaTypeGIF  = "image/gif"
+    MediaTypeWebP = "image/webp"
+    MediaTypePDF  = "application/pdf"
+)
+
+// ImageSource holds the data of an image or document content block. Base64
+// sources carry MediaType and Data; URL sources carry only URL.
+type ImageSource struct {
+    Type      string `json:"type"`
+    MediaType string `json:"media_type,omitempty"`
+    Data      string `json:"data,omitempty"`
+    URL       string `json:"url,omitempty"`
+}
+
+// Base64Image returns an image source for base64-encoded data
+func Base64Image(mediaType, data string) ImageSource {
+    return ImageSource{Type: ImageSourceBase64, MediaType: mediaType, Data: data}
+}
+
+// URLImage returns an image source the API fetches from url
+func URLImage(url string) ImageSource {
+    return ImageSource{Type: ImageSourceURL, URL: url}
 }
 
-// Parameters defines the input parameters for a function
-type Parameters struct {
+// InputSchema defines the input parameters for a tool
+type InputSchema struct {
     Type       string              `json:"type"`
     Properties map[string]Property `json:"properties"`
     Required   []string           `json:"required"`
 }
 
-// Property defines a single parameter's properties
+// Property defines a single parameter's properties. Items describes the
+// elements of an array; Properties and Required describe a nested object. An
+// empty Type accepts any value.
 type Property struct {
-    Type        string   `json:"type"`
-    Description string   `json:"description"`
-    Enum        []string `json:"enum,omitempty"`
+    Type        string              `json:"type,omitempty"`
+    Description string              `json:"description,omitempty"`
+    Enum        []string            `json:"enum,omitempty"`
+    Items       *Property           `json:"items,omitempty"`
+    Properties  map[string]Property `json:"properties,omitempty"`
+    Required    []string            `json:"required,omitempty"`
+}
+
+// ThinkingConfig enables extended thinking. BudgetTokens is the number of
+// tokens the model may spend reasoning; it must be at least 1024 and less than
+// MaxTokens.
+type ThinkingConfig struct {
+    Type         string `json:"type"`
+    BudgetTokens int    `json:"budget_tokens"`
+}
+
+// Float64 returns a pointer to v, for setting Temperature and TopP
+func Float64(v float64) *float64 {
+    return &v
+}
+
+// EnableThinking returns a thinking configuration with the given token budget
+func EnableThinking(budgetTokens int) *ThinkingConfig {
+    return &ThinkingConfig{Type: ThinkingEnabled, BudgetTokens: budgetTokens}
 }
 
 // ToolUse represents a tool call from the assistant
@@ -78,35 +172,118 @@ type ToolUse struct {
     Input json.RawMessage `json:"input"`
 }
 
-// MessageParams contains all possible parameters for a message request
+// MessageParams contains all possible parameters for a message request.
+// Temperature and TopP are pointers so that an explicit 0 can be told apart
+// from unset; use Float64 to set them.
 type MessageParams struct {
-    Model       string                 `json:"model"`
-    MaxTokens   int                    `json:"max_tokens"`
-    Temperature float64                `json:"temperature,omitempty"`
-    TopP        float64                `json:"top_p,omitempty"`
-    TopK        int                    `json:"top_k,omitempty"`
-    Metadata    map[string]interface{} `json:"metadata,omitempty"`
-    System      string                 `json:"system,omitempty"`
-    Tools       []Tool                 `json:"tools,omitempty"`
-    ToolChoice  *ToolChoice            `json:"tool_choice,omitempty"`
+    Model         string                 `json:"model"`
+    MaxTokens     int                    `json:"max_tokens"`
+    Temperature   *float64               `json:"temperature,omitempty"`
+    TopP          *float64               `json:"top_p,omitempty"`
+    TopK          int                    `json:"top_k,omitempty"`
+    Metadata      map[string]interface{} `json:"metadata,omitempty"`
+    StopSequences []string               `json:"stop_sequences,omitempty"`
+    System        string                 `json:"system,omitempty"`
+    Tools         []Tool                 `json:"tools,omitempty"`
+    ToolChoice    *ToolChoice            `json:"tool_choice,omitempty"`
+    Thinking      *ThinkingConfig        `json:"thinking,omitempty"`
+
+    // SystemBlocks sends the system prompt as separate blocks, so that a large
+    // static preamble can be cached while a dynamic suffix is not. When set it
+    // replaces System at the same level of precedence.
+    SystemBlocks []SystemBlock `json:"-"`
+
+    // Prefill seeds the start of the assistant's reply, for example "{" to
+    // force JSON. It is sent as a final assistant message and the model
+    // continues from it. The response text does not repeat the prefill, but
+    // the stored conversation holds the complete reply.
+    Prefill string `json:"-"`
+
+    // Messages optionally supplies the messages for calls that do not use the
+    // client's conversation, such as CountTokensBatch
+    Messages []Message `json:"messages,omitempty"`
+
+    // ConversationLimit overrides the client's maximum conversation length for
+    // a single call. Zero uses the client setting and NoConversationLimit
+    // disables trimming. Messages already trimmed by earlier calls are not restored.
+    ConversationLimit int `json:"-"`
 }
 
+// NoConversationLimit disables conversation trimming for a call when used as MessageParams.ConversationLimit
+const NoConversationLimit = -1
+
 // Request represents the complete structure sent to the Anthropic API
 type Request struct {
-    Model       string      `json:"model"`
-    Messages    []Message   `json:"messages"`
-    MaxTokens   int         `json:"max_tokens"`
-    Temperature float64     `json:"temperature,omitempty"`
-    TopP        float64     `json:"top_p,omitempty"`
-    TopK        int         `json:"top_k,omitempty"`
-    System      string      `json:"system,omitempty"`
-    Tools       []Tool      `json:"tools,omitempty"`
-    ToolChoice  *ToolChoice `json:"tool_choice,omitempty"`
+    Model         string                 `json:"model"`
+    Messages      []Message              `json:"messages"`
+    MaxTokens     int                    `json:"max_tokens"`
+    Temperature   *float64               `json:"temperature,omitempty"`
+    TopP          *float64               `json:"top_p,omitempty"`
+    TopK          int                    `json:"top_k,omitempty"`
+    StopSequences []string               `json:"stop_sequences,omitempty"`
+    Metadata      map[string]interface{} `json:"metadata,omitempty"`
+    System        string                 `json:"system,omitempty"`
+    Tools         []Tool                 `json:"tools,omitempty"`
+    ToolChoice    *ToolChoice            `json:"tool_choice,omitempty"`
+    Thinking      *ThinkingConfig        `json:"thinking,omitempty"`
+    Stream        bool                   `json:"stream,omitempty"`
+
+    // SystemCacheControl marks the system prompt as cacheable. When set the
+    // system prompt is sent as a text block carrying the marker.
+    SystemCacheControl *CacheControl `json:"-"`
+
+    // SystemBlocks, when set, is sent as the system prompt in place of System
+    // and SystemCacheControl. System then only holds the combined text.
+    SystemBlocks []SystemBlock `json:"-"`
+}
+
+// SystemBlock is one text block of a system prompt
+type SystemBlock struct {
+    Text         string        `json:"text"`
+    CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// SystemText joins the text of system blocks into a single prompt
+func SystemText(blocks []SystemBlock) string {
+    var text strings.Builder
+    for _, block := range blocks {
+        text.WriteString(block.Text)
+    }
+    return text.String()
+}
+
+// CountTokensRequest is the body sent to the token counting endpoint
+type CountTokensRequest struct {
+    Model      string      `json:"model"`
+    Messages   []Message   `json:"messages"`
+    System     string      `json:"system,omitempty"`
+    Tools      []Tool      `json:"tools,omitempty"`
+    ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+
+    // SystemCacheControl and SystemBlocks send the system prompt as on a
+    // Request, so that it is counted exactly as it will be sent
+    SystemCacheControl *CacheControl `json:"-"`
+    SystemBlocks       []SystemBlock `json:"-"`
+}
+
+// CountTokensResponse is returned by the token counting endpoint
+type CountTokensResponse struct {
+    InputTokens int `json:"input_tokens"`
 }
 
+// ToolChoice controls whether and how the model uses tools. Name is only used
+// with ToolChoiceTool. DisableParallelToolUse limits the model to at most one
+// tool call per turn (exactly one with ToolChoiceAny or ToolChoiceTool).
 type ToolChoice struct {
-    Type string `json:"type"`
-    Name string `json:"name,omitempty"`
+    Type                   string `json:"type"`
+    Name                   string `json:"name,omitempty"`
+    DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+// NoneToolChoice returns a tool choice that shows tools to the model without
+// letting it call them, for pure reasoning or summarizing turns
+func NoneToolChoice() *ToolChoice {
+    return &ToolChoice{Type: ToolChoiceNone}
 }
 
 // Response types
@@ -121,8 +298,10 @@ type AnthropicResponse struct {
 }
 
 type Usage struct {
-    InputTokens  int `json:"input_tokens"`
-    OutputTokens int `json:"output_tokens"`
+    InputTokens              int `json:"input_tokens"`
+    OutputTokens             int `json:"output_tokens"`
+    CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+    CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
 
 // ToolHandler interface for implementing tools
@@ -130,3 +309,137 @@ type ToolHandler interface {
     Execute(ctx context.Context, input json.RawMessage) (string, error)
     GetTool() Tool
 }
+
+// ToolHandlerFunc adapts a plain function to the ToolHandler interface
+type ToolHandlerFunc struct {
+    Tool Tool
+    Func func(ctx context.Context, input json.RawMessage) (string, error)
+}
+
+// Execute calls f.Func
+func (f ToolHandlerFunc) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+    return f.Func(ctx, input)
+}
+
+// GetTool returns f.Tool
+func (f ToolHandlerFunc) GetTool() Tool {
+    return f.Tool
+}
+
+// ToolResult is tool output made of content blocks, such as a rendered chart
+// returned as an image alongside a text description. Only text and image
+// blocks are allowed.
+type ToolResult struct {
+    Blocks []MessageContent
+}
+
+// Text returns the text blocks of the result joined together
+func (r ToolResult) Text() string {
+    var text strings.Builder
+    for _, block := range r.Blocks {
+        if block.Type == ContentTypeText {
+            text.WriteString(block.Text)
+        }
+    }
+    return text.String()
+}
+
+// ToolResultHandler is a ToolHandler whose results can include images. The
+// tool loop calls ExecuteResult in place of Execute for these handlers.
+type ToolResultHandler interface {
+    ToolHandler
+    ExecuteResult(ctx context.Context, input json.RawMessage) (ToolResult, error)
+}
+
+// ToolResultHandlerFunc adapts a function returning content blocks to the
+// ToolResultHandler interface
+type ToolResultHandlerFunc struct {
+    Tool Tool
+    Func func(ctx context.Context, input json.RawMessage) (ToolResult, error)
+}
+
+// Execute calls f.Func and returns only the text of its result
+func (f ToolResultHandlerFunc) Execute(ctx context.Context, input json.RawMessage) (string, error) {
+    result, err := f.Func(ctx, input)
+    return result.Text(), err
+}
+
+// ExecuteResult calls f.Func
+func (f ToolResultHandlerFunc) ExecuteResult(ctx context.Context, input json.RawMessage) (ToolResult, error) {
+    return f.Func(ctx, input)
+}
+
+// GetTool returns f.Tool
+func (f ToolResultHandlerFunc) GetTool() Tool {
+    return f.Tool
+}
+
+// ToolEvent describes one completed tool call made by ChatWithTools. Err is
+// set when the handler failed or panicked; Result then holds the error text
+// sent to the model.
+type ToolEvent struct {
+    Name      string
+    ToolUseID string
+    Input     json.RawMessage
+    Result    string
+    Err       error
+    Duration  time.Duration
+}
+
+// ToolResultMetric records the size of a single tool result. Bytes counts
+// its text together with the base64 data or URL of any image or document.
+type ToolResultMetric struct {
+    ToolName  string `json:"tool_name"`
+    ToolUseID string `json:"tool_use_id"`
+    Bytes     int    `json:"bytes"`
+}
+
+// ToolInteraction captures the tool calls made in one iteration of the tool
+// loop. Usage is that of the model response that requested the calls.
+type ToolInteraction struct {
+    Iteration int              `json:"iteration"`
+    Calls     []ToolCallRecord `json:"calls"`
+    Usage     Usage            `json:"usage"`
+}
+
+// ToolCallRecord pairs a tool call with the result it produced
+type ToolCallRecord struct {
+    ToolUse ToolUse `json:"tool_use"`
+    Result  string  `json:"result"`
+    Error   string  `json:"error,omitempty"`
+}
+
+// BlockStability describes how one part of the prompt varied across the requests of a session
+type BlockStability struct {
+    Block           string `json:"block"`
+    Requests        int    `json:"requests"`
+    Stable          bool   `json:"stable"`
+    Cached          bool   `json:"cached"`
+    EstimatedTokens int    `json:"estimated_tokens"`
+}
+
+// PromptRecord is a fully assembled prompt captured by prompt logging
+type PromptRecord struct {
+    Timestamp time.Time `json:"timestamp"`
+    Model     string    `json:"model"`
+    System    string    `json:"system,omitempty"`
+    Messages  []Message `json:"messages"`
+    Tools     []Tool    `json:"tools,omitempty"`
+}
+
+// Conversation event types
+const (
+    ConversationEventAppend = "append"
+    ConversationEventTrim   = "trim"
+    ConversationEventEdit   = "edit"
+    ConversationEventDelete = "delete"
+    ConversationEventClear  = "clear"
+)
+
+// ConversationEvent describes a change to a client's stored conversation
+type ConversationEvent struct {
+    Type    string   `json:"type"`
+    Message *Message `json:"message,omitempty"`
+    Removed int      `json:"removed,omitempty"`
+    Length  int      `json:"length"`
+}